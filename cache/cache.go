@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"context"
+	"errors"
+
+	"github.com/SourLemonJuice/ipapi-agent/config"
+	C "github.com/SourLemonJuice/ipapi-agent/constant"
+)
+
+// Backend stores V by key with the TTL of config.
+// Backends handle their own errors, an unavailable backend just misses.
+type Backend[V any] interface {
+	Get(ctx context.Context, key string) (V, bool)
+	Set(ctx context.Context, key string, value V)
+	Delete(ctx context.Context, key string)
+	// flush the pending writes and release the resources
+	Close() error
+}
+
+// Return the backend of config, or one that never stores anything if the cache is disabled.
+func New[V any](conf config.ConfigCache) (Backend[V], error) {
+	if !conf.Enabled {
+		return disabled[V]{}, nil
+	}
+
+	switch conf.Backend {
+	case C.CacheBackendRedis:
+		shared, err := newRedis[V](conf.Redis, conf.TTL)
+		if err != nil {
+			return nil, err
+		}
+		if conf.Redis.LocalTTL == 0 {
+			return shared, nil
+		}
+
+		localConf := conf
+		localConf.TTL = min(conf.Redis.LocalTTL, conf.TTL)
+		local, err := newMemory[V](localConf)
+		if err != nil {
+			return nil, err
+		}
+		return &tiered[V]{local: local, shared: shared}, nil
+	default:
+		return newMemory[V](conf)
+	}
+}
+
+type disabled[V any] struct{}
+
+func (disabled[V]) Get(context.Context, string) (V, bool) {
+	var zero V
+	return zero, false
+}
+
+func (disabled[V]) Set(context.Context, string, V) {}
+
+func (disabled[V]) Delete(context.Context, string) {}
+
+func (disabled[V]) Close() error {
+	return nil
+}
+
+// A local backend in front of a shared one, the local one is checked first and filled on the shared hits.
+type tiered[V any] struct {
+	local  Backend[V]
+	shared Backend[V]
+}
+
+func (t *tiered[V]) Get(ctx context.Context, key string) (V, bool) {
+	if value, ok := t.local.Get(ctx, key); ok {
+		return value, true
+	}
+
+	value, ok := t.shared.Get(ctx, key)
+	if ok {
+		t.local.Set(ctx, key, value)
+	}
+	return value, ok
+}
+
+func (t *tiered[V]) Set(ctx context.Context, key string, value V) {
+	t.local.Set(ctx, key, value)
+	t.shared.Set(ctx, key, value)
+}
+
+func (t *tiered[V]) Delete(ctx context.Context, key string) {
+	t.local.Delete(ctx, key)
+	t.shared.Delete(ctx, key)
+}
+
+func (t *tiered[V]) Close() error {
+	return errors.Join(t.local.Close(), t.shared.Close())
+}
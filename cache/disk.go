@@ -0,0 +1,315 @@
+package cache
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"go.etcd.io/bbolt"
+	berrors "go.etcd.io/bbolt/errors"
+
+	"github.com/SourLemonJuice/ipapi-agent/debug"
+)
+
+// Bump it when the stored format changes, the old files are emptied instead of being decoded.
+const diskVersion = 1
+
+var (
+	bucketMeta    = []byte("meta")
+	bucketEntries = []byte("entries")
+	keyVersion    = []byte("version")
+)
+
+// Writes are queued, a full queue drops them instead of blocking the requests.
+const diskQueueSize = 1024
+
+// A bbolt file that persists the entries of the memory backend with their expiration.
+// Every value is an 8 bytes expiration(Unix nanoseconds) followed by the JSON of V.
+type disk[V any] struct {
+	db    *bbolt.DB
+	queue chan diskWrite[V]
+	done  chan struct{}
+	once  sync.Once
+}
+
+// Encoded in the writing goroutine, not the request one.
+type diskWrite[V any] struct {
+	key        string
+	value      V
+	expiration time.Time
+	delete     bool
+}
+
+// Open the file at path and return its unexpired entries, oldest first.
+// A corrupted file is moved aside to "<path>.corrupted", and a new one is created.
+func openDisk[V any](path string) (*disk[V], []diskEntry[V], error) {
+	d, entries, err := loadDisk[V](path)
+	if err == nil || !isCorrupted(err) {
+		return d, entries, err
+	}
+
+	debug.Logger.Printf("Disk cache %v is corrupted, moving it aside: %v", path, err)
+	err = os.Rename(path, path+".corrupted")
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't move the corrupted disk cache: %w", err)
+	}
+	return loadDisk[V](path)
+}
+
+// Errors of the file content, the others like permission or lock timeout are not.
+func isCorrupted(err error) bool {
+	return errors.Is(err, berrors.ErrInvalid) ||
+		errors.Is(err, berrors.ErrInvalidMapping) ||
+		errors.Is(err, berrors.ErrVersionMismatch) ||
+		errors.Is(err, berrors.ErrChecksum) ||
+		errors.Is(err, errPanic)
+}
+
+var errPanic = errors.New("panic while reading")
+
+type diskEntry[V any] struct {
+	key        string
+	value      V
+	expiration time.Time
+}
+
+func loadDisk[V any](path string) (d *disk[V], entries []diskEntry[V], err error) {
+	// wait a moment for the lock, another instance may be using it
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		if errors.Is(err, berrors.ErrTimeout) {
+			return nil, nil, fmt.Errorf("disk cache %v is locked by another process", path)
+		}
+		// errors of the file system, like permission denied
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			return nil, nil, err
+		}
+		// bbolt reports a file that isn't a database or is truncated with various errors
+		return nil, nil, fmt.Errorf("%w: %w", berrors.ErrInvalid, err)
+	}
+
+	// bbolt panics on some broken pages
+	defer func() {
+		if r := recover(); r != nil {
+			db.Close()
+			d, entries, err = nil, nil, fmt.Errorf("%w: %v", errPanic, r)
+		}
+	}()
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(bucketMeta)
+		if err != nil {
+			return err
+		}
+
+		version := diskVersionOf[V]()
+		if string(meta.Get(keyVersion)) != version {
+			debug.Logger.Printf("Disk cache version mismatched, %q is expected, emptied", version)
+			err = tx.DeleteBucket(bucketEntries)
+			if err != nil && !errors.Is(err, berrors.ErrBucketNotFound) {
+				return err
+			}
+			err = meta.Put(keyVersion, []byte(version))
+			if err != nil {
+				return err
+			}
+		}
+
+		bucket, err := tx.CreateBucketIfNotExists(bucketEntries)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		var stale [][]byte
+		err = bucket.ForEach(func(k, v []byte) error {
+			entry, ok := decodeEntry[V](k, v)
+			if !ok || now.After(entry.expiration) {
+				stale = append(stale, k)
+				return nil
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range stale {
+			err = bucket.Delete(k)
+			if err != nil {
+				return err
+			}
+		}
+		debug.Logger.Printf("Disk cache loaded, entries: %v, removed: %v", len(entries), len(stale))
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+
+	d = &disk[V]{
+		db:    db,
+		queue: make(chan diskWrite[V], diskQueueSize),
+		done:  make(chan struct{}),
+	}
+	go d.writeLoop()
+
+	return d, sortByExpiration(entries), nil
+}
+
+// Like "1/response.Query", a file of another type is also a mismatch.
+func diskVersionOf[V any]() string {
+	var zero V
+	return fmt.Sprintf("%v/%T", diskVersion, zero)
+}
+
+func encodeEntry[V any](value V, expiration time.Time) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return append(binary.BigEndian.AppendUint64(nil, uint64(expiration.UnixNano())), data...), nil
+}
+
+func decodeEntry[V any](k []byte, v []byte) (diskEntry[V], bool) {
+	entry := diskEntry[V]{key: string(k)}
+	if len(v) < 8 {
+		return entry, false
+	}
+
+	entry.expiration = time.Unix(0, int64(binary.BigEndian.Uint64(v[:8])))
+	err := json.Unmarshal(v[8:], &entry.value)
+	return entry, err == nil
+}
+
+func sortByExpiration[V any](entries []diskEntry[V]) []diskEntry[V] {
+	// entries with the same TTL expire in the order they were stored
+	slices.SortFunc(entries, func(a, b diskEntry[V]) int {
+		return a.expiration.Compare(b.expiration)
+	})
+	return entries
+}
+
+// Queue the value, never blocks. Nil-safe, like the other methods.
+func (d *disk[V]) put(key string, value V, expiration time.Time) {
+	if d == nil {
+		return
+	}
+
+	d.send(diskWrite[V]{key: key, value: value, expiration: expiration})
+}
+
+func (d *disk[V]) delete(key string) {
+	if d == nil {
+		return
+	}
+	d.send(diskWrite[V]{key: key, delete: true})
+}
+
+func (d *disk[V]) send(w diskWrite[V]) {
+	select {
+	case d.queue <- w:
+	default:
+		debug.Logger.Printf("Disk cache queue is full, dropped %v", w.key)
+	}
+}
+
+// Write the queued entries in batches, one transaction for the ones already queued.
+func (d *disk[V]) writeLoop() {
+	defer close(d.done)
+
+	for w := range d.queue {
+		batch := []diskWrite[V]{w}
+	drain:
+		for len(batch) < diskQueueSize {
+			select {
+			case w, ok := <-d.queue:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, w)
+			default:
+				break drain
+			}
+		}
+
+		err := d.db.Update(func(tx *bbolt.Tx) error {
+			bucket := tx.Bucket(bucketEntries)
+			for _, w := range batch {
+				if w.delete {
+					err := bucket.Delete([]byte(w.key))
+					if err != nil {
+						return err
+					}
+					continue
+				}
+
+				data, err := encodeEntry(w.value, w.expiration)
+				if err != nil {
+					debug.Logger.Printf("Can't encode the disk cache entry %v: %v", w.key, err)
+					continue
+				}
+				err = bucket.Put([]byte(w.key), data)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			debug.Logger.Printf("Disk cache write error, %v entries lost: %v", len(batch), err)
+		}
+	}
+}
+
+// Remove the expired entries from the file.
+func (d *disk[V]) deleteExpired() {
+	if d == nil {
+		return
+	}
+
+	now := time.Now()
+	err := d.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(bucketEntries)
+		cursor := bucket.Cursor()
+		for k, v := cursor.First(); k != nil; {
+			if len(v) < 8 || now.UnixNano() > int64(binary.BigEndian.Uint64(v[:8])) {
+				// k is invalid after deleting, seek the next one with a copy
+				key := slices.Clone(k)
+				err := cursor.Delete()
+				if err != nil {
+					return err
+				}
+				k, v = cursor.Seek(key)
+				continue
+			}
+			k, v = cursor.Next()
+		}
+		return nil
+	})
+	if err != nil {
+		debug.Logger.Printf("Disk cache cleanup error: %v", err)
+	}
+}
+
+// Write the queued entries and close the file.
+func (d *disk[V]) close() error {
+	if d == nil {
+		return nil
+	}
+
+	d.once.Do(func() {
+		close(d.queue)
+	})
+	<-d.done
+	return d.db.Close()
+}
@@ -0,0 +1,318 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+type testValue struct {
+	Name string
+	N    int
+}
+
+func testDiskPath(t *testing.T) string {
+	t.Helper()
+	return filepath.Join(t.TempDir(), "cache.db")
+}
+
+func openTestDisk[V any](t *testing.T, path string) (*disk[V], []diskEntry[V]) {
+	t.Helper()
+	d, entries, err := openDisk[V](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		d.close()
+	})
+	return d, entries
+}
+
+// Modify the file directly, like another version of the program did.
+func updateFile(t *testing.T, path string, fn func(tx *bbolt.Tx) error) {
+	t.Helper()
+	db, err := bbolt.Open(path, 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Update(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// The keys in the entries bucket.
+func fileKeys(t *testing.T, path string) []string {
+	t.Helper()
+	db, err := bbolt.Open(path, 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var keys []string
+	err = db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(bucketEntries).ForEach(func(k, v []byte) error {
+			keys = append(keys, string(k))
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestDiskReload(t *testing.T) {
+	ctx := context.Background()
+	conf := testConf()
+	conf.DiskPath = testDiskPath(t)
+
+	m, err := newMemory[testValue](conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Set(ctx, "a", testValue{Name: "a", N: 1})
+	m.Set(ctx, "b", testValue{Name: "b", N: 2})
+	m.Set(ctx, "c", testValue{Name: "c", N: 3})
+	m.Delete(ctx, "c")
+	err = m.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m = newTestMemory[testValue](t, conf)
+	for _, want := range []testValue{{Name: "a", N: 1}, {Name: "b", N: 2}} {
+		got, ok := m.Get(ctx, want.Name)
+		if !ok || got != want {
+			t.Errorf("Get(%v) = %v, %v after reload, want %v", want.Name, got, ok, want)
+		}
+	}
+	if _, ok := m.Get(ctx, "c"); ok {
+		t.Error("deleted entry is reloaded")
+	}
+}
+
+func TestDiskReloadKeepsLimits(t *testing.T) {
+	ctx := context.Background()
+	conf := testConf()
+	conf.DiskPath = testDiskPath(t)
+
+	m, err := newMemory[testValue](conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 5 {
+		m.Set(ctx, fmt.Sprint(i), testValue{N: i})
+		// distinct expirations, they are reloaded in this order
+		time.Sleep(time.Millisecond)
+	}
+	m.Close()
+
+	// the ones expiring first are evicted
+	conf.MaxEntries = 2
+	m = newTestMemory[testValue](t, conf)
+	for key, want := range map[string]bool{"0": false, "2": false, "3": true, "4": true} {
+		if _, ok := m.Get(ctx, key); ok != want {
+			t.Errorf("Get(%v) hit %v, want %v", key, ok, want)
+		}
+	}
+}
+
+func TestDiskSkipsStaleEntries(t *testing.T) {
+	path := testDiskPath(t)
+	d, _ := openTestDisk[testValue](t, path)
+	d.put("expired", testValue{Name: "expired"}, time.Now().Add(-time.Minute))
+	d.put("fresh", testValue{Name: "fresh"}, time.Now().Add(time.Hour))
+	err := d.close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updateFile(t, path, func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(bucketEntries)
+		value, _ := encodeEntry("not an object", time.Now().Add(time.Hour))
+		err := bucket.Put([]byte("undecodable"), value)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte("short"), []byte{1, 2, 3})
+	})
+
+	d, entries := openTestDisk[testValue](t, path)
+	if len(entries) != 1 || entries[0].key != "fresh" || entries[0].value.Name != "fresh" {
+		t.Errorf("got entries %+v, want only the fresh one", entries)
+	}
+	d.close()
+
+	// removed from the file too
+	keys := fileKeys(t, path)
+	if len(keys) != 1 || keys[0] != "fresh" {
+		t.Errorf("got keys %v in the file, want [fresh]", keys)
+	}
+}
+
+func TestDiskDeleteExpired(t *testing.T) {
+	path := testDiskPath(t)
+	d, _, err := openDisk[testValue](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 10 {
+		expiration := time.Now().Add(time.Hour)
+		if i%2 == 0 {
+			expiration = time.Now().Add(50 * time.Millisecond)
+		}
+		d.put(fmt.Sprint(i), testValue{N: i}, expiration)
+	}
+	// wait for the writes
+	d.once.Do(func() {
+		close(d.queue)
+	})
+	<-d.done
+
+	time.Sleep(100 * time.Millisecond)
+	d.deleteExpired()
+	d.db.Close()
+
+	keys := fileKeys(t, path)
+	want := []string{"1", "3", "5", "7", "9"}
+	if fmt.Sprint(keys) != fmt.Sprint(want) {
+		t.Errorf("got keys %v in the file, want %v", keys, want)
+	}
+}
+
+func TestDiskCorrupted(t *testing.T) {
+	path := testDiskPath(t)
+	garbage := bytes.Repeat([]byte("not a bbolt file "), 1024)
+	err := os.WriteFile(path, garbage, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, entries := openTestDisk[testValue](t, path)
+	if len(entries) != 0 {
+		t.Errorf("got %v entries from a corrupted file", len(entries))
+	}
+
+	moved, err := os.ReadFile(path + ".corrupted")
+	if err != nil {
+		t.Fatalf("the corrupted file isn't moved aside: %v", err)
+	}
+	if !bytes.Equal(moved, garbage) {
+		t.Error("the corrupted file is changed")
+	}
+
+	// the new one works
+	d.put("a", testValue{Name: "a"}, time.Now().Add(time.Hour))
+	d.close()
+	_, entries = openTestDisk[testValue](t, path)
+	if len(entries) != 1 || entries[0].value.Name != "a" {
+		t.Errorf("got entries %+v from the new file, want a", entries)
+	}
+}
+
+func TestDiskTruncated(t *testing.T) {
+	path := testDiskPath(t)
+	d, _ := openTestDisk[testValue](t, path)
+	d.close()
+
+	// only the first page is left
+	err := os.Truncate(path, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, entries := openTestDisk[testValue](t, path)
+	if len(entries) != 0 {
+		t.Errorf("got %v entries from a truncated file", len(entries))
+	}
+	if _, err := os.Stat(path + ".corrupted"); err != nil {
+		t.Errorf("the truncated file isn't moved aside: %v", err)
+	}
+}
+
+func TestDiskVersionMismatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+	}{
+		{"old version", "0/cache.testValue"},
+		{"another type", fmt.Sprintf("%v/string", diskVersion)},
+		{"no version", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := testDiskPath(t)
+			d, _ := openTestDisk[testValue](t, path)
+			d.put("a", testValue{Name: "a"}, time.Now().Add(time.Hour))
+			d.close()
+
+			updateFile(t, path, func(tx *bbolt.Tx) error {
+				if tt.version == "" {
+					return tx.Bucket(bucketMeta).Delete(keyVersion)
+				}
+				return tx.Bucket(bucketMeta).Put(keyVersion, []byte(tt.version))
+			})
+
+			_, entries := openTestDisk[testValue](t, path)
+			if len(entries) != 0 {
+				t.Errorf("got %v entries with version %q, want the store emptied", len(entries), tt.version)
+			}
+		})
+	}
+
+	if got, want := diskVersionOf[testValue](), "1/cache.testValue"; got != want {
+		t.Errorf("diskVersionOf() = %q, want %q", got, want)
+	}
+}
+
+func TestDiskFlushOnClose(t *testing.T) {
+	ctx := context.Background()
+	conf := testConf()
+	conf.DiskPath = testDiskPath(t)
+
+	m, err := newMemory[testValue](conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// no wait before closing, the queued ones are still written
+	const n = diskQueueSize / 2
+	for i := range n {
+		m.Set(ctx, fmt.Sprint(i), testValue{N: i})
+	}
+	err = m.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing is written after closing
+	m.Set(ctx, "late", testValue{})
+
+	keys := fileKeys(t, conf.DiskPath)
+	if len(keys) != n {
+		t.Errorf("got %v entries in the file, want %v", len(keys), n)
+	}
+}
+
+func TestDiskLocked(t *testing.T) {
+	path := testDiskPath(t)
+	openTestDisk[testValue](t, path)
+
+	_, _, err := openDisk[testValue](path)
+	if err == nil {
+		t.Fatal("a locked file is opened twice")
+	}
+	if _, statErr := os.Stat(path + ".corrupted"); statErr == nil {
+		t.Error("a locked file is moved aside")
+	}
+}
@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"container/list"
+	"time"
+)
+
+// Local is an in-memory LRU cache with the TTL of each entry, for internal caches that are not configured by [cache].
+type Local[V any] struct {
+	m *memory[V]
+}
+
+// Entries over maxEntries are evicted from the least recently used, 0 means no limit.
+// Expired entries are removed every cleanupInterval, call Close() to stop it.
+func NewLocal[V any](maxEntries int, cleanupInterval time.Duration) *Local[V] {
+	m := &memory[V]{
+		maxEntries: maxEntries,
+		lru:        list.New(),
+		items:      make(map[string]*list.Element),
+		stop:       make(chan struct{}),
+	}
+	go m.cleanupLoop(cleanupInterval)
+
+	return &Local[V]{m: m}
+}
+
+// Return the value of key and its expiration time.
+func (l *Local[V]) Get(key string) (V, time.Time, bool) {
+	l.m.mu.Lock()
+	defer l.m.mu.Unlock()
+
+	e := l.m.load(key)
+	if e == nil {
+		var zero V
+		return zero, time.Time{}, false
+	}
+	return e.value, e.expiration, true
+}
+
+func (l *Local[V]) Set(key string, value V, ttl time.Duration) {
+	l.m.mu.Lock()
+	defer l.m.mu.Unlock()
+
+	l.m.store(key, value, time.Now().Add(ttl))
+}
+
+// The number of entries, expired ones that are not cleaned up yet included.
+func (l *Local[V]) Len() int {
+	return l.m.Len()
+}
+
+// Stop the cleanup.
+func (l *Local[V]) Close() {
+	close(l.m.stop)
+}
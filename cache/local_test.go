@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLocal(t *testing.T) {
+	l := NewLocal[string](2, time.Hour)
+	defer l.Close()
+
+	l.Set("a", "a", time.Hour)
+	l.Set("short", "short", 50*time.Millisecond)
+	value, expiration, ok := l.Get("a")
+	if !ok || value != "a" {
+		t.Fatalf("Get() = %q, %v, want a hit", value, ok)
+	}
+	if d := time.Until(expiration); d <= 0 || d > time.Hour {
+		t.Errorf("got expiration in %v, want within the TTL", d)
+	}
+
+	// the TTL of each entry
+	time.Sleep(100 * time.Millisecond)
+	if _, _, ok := l.Get("short"); ok {
+		t.Error("expired entry is returned")
+	}
+
+	// "a" is used more recently than "b"
+	l.Set("b", "b", time.Hour)
+	l.Get("a")
+	l.Set("c", "c", time.Hour)
+	if _, _, ok := l.Get("b"); ok {
+		t.Error("the least recently used entry isn't evicted")
+	}
+	if l.Len() != 2 {
+		t.Errorf("got %v entries, want 2", l.Len())
+	}
+}
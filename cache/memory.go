@@ -0,0 +1,203 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/SourLemonJuice/ipapi-agent/config"
+	"github.com/SourLemonJuice/ipapi-agent/debug"
+)
+
+// The in-memory backend with TTL, the least recently used entries are evicted when it's full.
+type memory[V any] struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	maxBytes   int64
+	bytes      int64
+	// front is the most recently used
+	lru   *list.List
+	items map[string]*list.Element
+	stop  chan struct{}
+	// nil if disk_path isn't set
+	disk *disk[V]
+}
+
+type entry[V any] struct {
+	key        string
+	value      V
+	expiration time.Time
+	size       int64
+}
+
+// With disk_path, the entries in the file are loaded, and the new ones are written to it in background.
+// Expired entries are removed every cleanup_interval, call Close() to stop it.
+func newMemory[V any](conf config.ConfigCache) (*memory[V], error) {
+	m := &memory[V]{
+		ttl:        conf.TTL,
+		maxEntries: conf.MaxEntries,
+		maxBytes:   int64(conf.MaxMemoryMB) << 20,
+		lru:        list.New(),
+		items:      make(map[string]*list.Element),
+		stop:       make(chan struct{}),
+	}
+
+	if len(conf.DiskPath) > 0 {
+		d, entries, err := openDisk[V](conf.DiskPath)
+		if err != nil {
+			return nil, fmt.Errorf("cache.disk_path: %w", err)
+		}
+		for _, e := range entries {
+			m.store(e.key, e.value, e.expiration)
+		}
+		m.disk = d
+	}
+
+	go m.cleanupLoop(conf.CleanupInterval)
+
+	return m, nil
+}
+
+func (m *memory[V]) Get(_ context.Context, key string) (V, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := m.load(key)
+	if e == nil {
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+// Return the unexpired entry of key and mark it as recently used, or nil.
+func (m *memory[V]) load(key string) *entry[V] {
+	elem, ok := m.items[key]
+	if !ok {
+		return nil
+	}
+	e := elem.Value.(*entry[V])
+	if time.Now().After(e.expiration) {
+		m.remove(elem)
+		return nil
+	}
+
+	m.lru.MoveToFront(elem)
+	return e
+}
+
+// Store value with the default TTL, then evict entries until the limits are met.
+func (m *memory[V]) Set(_ context.Context, key string, value V) {
+	expiration := time.Now().Add(m.ttl)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.store(key, value, expiration)
+	m.disk.put(key, value, expiration)
+}
+
+func (m *memory[V]) store(key string, value V, expiration time.Time) {
+	e := &entry[V]{
+		key:        key,
+		value:      value,
+		expiration: expiration,
+		size:       int64(len(key)) + sizeOf(value),
+	}
+
+	if elem, ok := m.items[key]; ok {
+		m.remove(elem)
+	}
+	m.items[key] = m.lru.PushFront(e)
+	m.bytes += e.size
+
+	for m.full() {
+		m.remove(m.lru.Back())
+	}
+}
+
+func (m *memory[V]) Delete(_ context.Context, key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if elem, ok := m.items[key]; ok {
+		m.remove(elem)
+	}
+	m.disk.delete(key)
+}
+
+// The number of entries, expired ones that are not cleaned up yet included.
+func (m *memory[V]) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.items)
+}
+
+// Stop the cleanup, and flush the queued writes to the disk.
+// The cache is still usable, but nothing is written to the disk anymore.
+func (m *memory[V]) Close() error {
+	close(m.stop)
+
+	m.mu.Lock()
+	d := m.disk
+	m.disk = nil
+	m.mu.Unlock()
+
+	return d.close()
+}
+
+// Whether the limits are exceeded, the last entry is always kept.
+func (m *memory[V]) full() bool {
+	if m.lru.Len() <= 1 {
+		return false
+	}
+	if m.maxEntries > 0 && m.lru.Len() > m.maxEntries {
+		return true
+	}
+	return m.maxBytes > 0 && m.bytes > m.maxBytes
+}
+
+func (m *memory[V]) remove(elem *list.Element) {
+	e := m.lru.Remove(elem).(*entry[V])
+	delete(m.items, e.key)
+	m.bytes -= e.size
+}
+
+func (m *memory[V]) cleanupLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.deleteExpired()
+			m.mu.Lock()
+			d := m.disk
+			m.mu.Unlock()
+			d.deleteExpired()
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+func (m *memory[V]) deleteExpired() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	removed := 0
+	for elem := m.lru.Back(); elem != nil; {
+		prev := elem.Prev()
+		if now.After(elem.Value.(*entry[V]).expiration) {
+			m.remove(elem)
+			removed++
+		}
+		elem = prev
+	}
+
+	debug.Logger.Printf("Cache cleanup, removed: %v, left: %v, approximate bytes: %v", removed, len(m.items), m.bytes)
+}
@@ -0,0 +1,199 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SourLemonJuice/ipapi-agent/config"
+	C "github.com/SourLemonJuice/ipapi-agent/constant"
+)
+
+// A memory config without limits and cleanup in tests.
+func testConf() config.ConfigCache {
+	return config.ConfigCache{
+		Enabled:         true,
+		Backend:         C.CacheBackendMemory,
+		TTL:             time.Hour,
+		CleanupInterval: time.Hour,
+	}
+}
+
+func newTestMemory[V any](t *testing.T, conf config.ConfigCache) *memory[V] {
+	t.Helper()
+	m, err := newMemory[V](conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		m.Close()
+	})
+	return m
+}
+
+func TestMemoryEviction(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		maxEntries  int
+		maxMemoryMB int
+		valueSize   int
+		// "+k" sets k, "?k" gets k
+		ops     []string
+		want    []string
+		missing []string
+	}{
+		{"no limit", 0, 0, 10, []string{"+a", "+b", "+c"}, []string{"a", "b", "c"}, nil},
+		{"max entries", 2, 0, 10, []string{"+a", "+b", "+c"}, []string{"b", "c"}, []string{"a"}},
+		{"get refreshes", 2, 0, 10, []string{"+a", "+b", "?a", "+c"}, []string{"a", "c"}, []string{"b"}},
+		{"set refreshes", 2, 0, 10, []string{"+a", "+b", "+a", "+c"}, []string{"a", "c"}, []string{"b"}},
+		{"overwrite counts once", 2, 0, 10, []string{"+a", "+a", "+b"}, []string{"a", "b"}, nil},
+		// 3 of 300 KiB values fit in 1 MiB
+		{"byte cap", 0, 1, 300 << 10, []string{"+a", "+b", "+c", "+d"}, []string{"b", "c", "d"}, []string{"a"}},
+		{"byte cap with get", 0, 1, 300 << 10, []string{"+a", "+b", "+c", "?a", "+d"}, []string{"a", "c", "d"}, []string{"b"}},
+		{"both limits", 2, 1, 300 << 10, []string{"+a", "+b", "+c"}, []string{"b", "c"}, []string{"a"}},
+		// the last one is kept even if it's larger than the cap
+		{"oversized entry", 0, 1, 2 << 20, []string{"+a", "+b"}, []string{"b"}, []string{"a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := testConf()
+			conf.MaxEntries = tt.maxEntries
+			conf.MaxMemoryMB = tt.maxMemoryMB
+			m := newTestMemory[string](t, conf)
+
+			value := strings.Repeat("x", tt.valueSize)
+			for _, op := range tt.ops {
+				switch op[0] {
+				case '+':
+					m.Set(ctx, op[1:], value)
+				case '?':
+					m.Get(ctx, op[1:])
+				}
+			}
+
+			for _, key := range tt.want {
+				if _, ok := m.Get(ctx, key); !ok {
+					t.Errorf("%v is evicted", key)
+				}
+			}
+			for _, key := range tt.missing {
+				if _, ok := m.Get(ctx, key); ok {
+					t.Errorf("%v isn't evicted", key)
+				}
+			}
+			if m.Len() != len(tt.want) {
+				t.Errorf("got %v entries, want %v", m.Len(), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestMemoryBytes(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMemory[string](t, testConf())
+
+	m.Set(ctx, "a", "value")
+	m.Set(ctx, "b", strings.Repeat("x", 1000))
+	m.mu.Lock()
+	bytes := m.bytes
+	m.mu.Unlock()
+	if want := 2*entryOverhead + 2*16 + 2 + 5 + 1000; bytes != int64(want) {
+		t.Errorf("got %v bytes, want %v", bytes, want)
+	}
+
+	// the size of the replaced one is subtracted
+	m.Set(ctx, "b", "")
+	m.Delete(ctx, "a")
+	m.Delete(ctx, "missing")
+	m.mu.Lock()
+	bytes = m.bytes
+	m.mu.Unlock()
+	if want := entryOverhead + 16 + 1; bytes != int64(want) {
+		t.Errorf("got %v bytes, want %v", bytes, want)
+	}
+}
+
+func TestMemoryExpiration(t *testing.T) {
+	ctx := context.Background()
+	conf := testConf()
+	conf.TTL = 50 * time.Millisecond
+	m := newTestMemory[string](t, conf)
+
+	m.Set(ctx, "a", "value")
+	value, ok := m.Get(ctx, "a")
+	if !ok || value != "value" {
+		t.Fatalf("Get() = %q, %v, want a hit", value, ok)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if _, ok := m.Get(ctx, "a"); ok {
+		t.Error("expired entry is returned")
+	}
+	// removed by Get
+	if m.Len() != 0 {
+		t.Errorf("got %v entries after expiration, want 0", m.Len())
+	}
+}
+
+func TestMemoryCleanup(t *testing.T) {
+	ctx := context.Background()
+	conf := testConf()
+	conf.TTL = 20 * time.Millisecond
+	conf.CleanupInterval = 10 * time.Millisecond
+	m := newTestMemory[string](t, conf)
+
+	for _, key := range []string{"a", "b", "c"} {
+		m.Set(ctx, key, "value")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for m.Len() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%v entries are left after the cleanup", m.Len())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.bytes != 0 || m.lru.Len() != 0 {
+		t.Errorf("got %v bytes and %v list elements after the cleanup, want 0", m.bytes, m.lru.Len())
+	}
+}
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+
+	conf := testConf()
+	backend, err := New[string](conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+	if _, ok := backend.(*memory[string]); !ok {
+		t.Errorf("got %T, want the memory backend", backend)
+	}
+
+	conf.Enabled = false
+	backend, err = New[string](conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := backend.(disabled[string]); !ok {
+		t.Errorf("got %T, want the disabled backend", backend)
+	}
+
+	// never stores anything
+	backend.Set(ctx, "a", "value")
+	if value, ok := backend.Get(ctx, "a"); ok || value != "" {
+		t.Errorf("disabled Get() = %q, %v, want a miss", value, ok)
+	}
+	backend.Delete(ctx, "a")
+	if err := backend.Close(); err != nil {
+		t.Errorf("disabled Close() = %v", err)
+	}
+}
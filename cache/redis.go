@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/SourLemonJuice/ipapi-agent/config"
+	"github.com/SourLemonJuice/ipapi-agent/debug"
+)
+
+// The backend on a Redis-protocol server, shared by the instances with the same key prefix.
+// Values are the JSON of V, expired by the server.
+type redisBackend[V any] struct {
+	client  *redis.Client
+	prefix  string
+	ttl     time.Duration
+	timeout time.Duration
+}
+
+// The server should be reachable at startup, so a wrong config is found early.
+func newRedis[V any](conf config.ConfigCacheRedis, ttl time.Duration) (*redisBackend[V], error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:         conf.Address,
+		Username:     conf.Username,
+		Password:     conf.Password,
+		DB:           conf.DB,
+		DialTimeout:  conf.Timeout,
+		ReadTimeout:  conf.Timeout,
+		WriteTimeout: conf.Timeout,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), conf.Timeout)
+	defer cancel()
+	err := client.Ping(ctx).Err()
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("can't connect to cache.redis.address %v: %w", conf.Address, err)
+	}
+
+	return &redisBackend[V]{
+		client:  client,
+		prefix:  conf.KeyPrefix,
+		ttl:     ttl,
+		timeout: conf.Timeout,
+	}, nil
+}
+
+func (r *redisBackend[V]) Get(ctx context.Context, key string) (V, bool) {
+	var value V
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	data, err := r.client.Get(ctx, r.prefix+key).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			debug.Logger.Printf("Redis cache get %v error: %v", key, err)
+		}
+		return value, false
+	}
+
+	// may be written by another version
+	err = json.Unmarshal(data, &value)
+	if err != nil {
+		debug.Logger.Printf("Redis cache entry %v is broken: %v", key, err)
+		return value, false
+	}
+	return value, true
+}
+
+func (r *redisBackend[V]) Set(ctx context.Context, key string, value V) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		debug.Logger.Printf("Can't encode the Redis cache entry %v: %v", key, err)
+		return
+	}
+
+	// the result is already fetched, keep it even if the request is gone
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), r.timeout)
+	defer cancel()
+
+	err = r.client.Set(ctx, r.prefix+key, data, r.ttl).Err()
+	if err != nil {
+		debug.Logger.Printf("Redis cache set %v error: %v", key, err)
+	}
+}
+
+func (r *redisBackend[V]) Delete(ctx context.Context, key string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), r.timeout)
+	defer cancel()
+
+	err := r.client.Del(ctx, r.prefix+key).Err()
+	if err != nil {
+		debug.Logger.Printf("Redis cache delete %v error: %v", key, err)
+	}
+}
+
+func (r *redisBackend[V]) Close() error {
+	return r.client.Close()
+}
@@ -0,0 +1,295 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/SourLemonJuice/ipapi-agent/config"
+	C "github.com/SourLemonJuice/ipapi-agent/constant"
+)
+
+// A redis config of a local miniredis server.
+func testRedisConf(t *testing.T) (*miniredis.Miniredis, config.ConfigCache) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+
+	conf := testConf()
+	conf.Backend = C.CacheBackendRedis
+	conf.Redis = config.DefaultCacheRedis
+	conf.Redis.Address = mr.Addr()
+	conf.Redis.KeyPrefix = "test:"
+	conf.Redis.LocalTTL = 0
+	return mr, conf
+}
+
+func newTestRedis[V any](t *testing.T, conf config.ConfigCache) *redisBackend[V] {
+	t.Helper()
+	r, err := newRedis[V](conf.Redis, conf.TTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		r.Close()
+	})
+	return r
+}
+
+func TestRedisSet(t *testing.T) {
+	ctx := context.Background()
+	mr, conf := testRedisConf(t)
+	r := newTestRedis[testValue](t, conf)
+
+	want := testValue{Name: "a", N: 1}
+	r.Set(ctx, "a", want)
+
+	// stored with the key prefix and the TTL
+	if keys := mr.Keys(); len(keys) != 1 || keys[0] != "test:a" {
+		t.Errorf("got keys %v, want [test:a]", keys)
+	}
+	if ttl := mr.TTL("test:a"); ttl != conf.TTL {
+		t.Errorf("got TTL %v, want %v", ttl, conf.TTL)
+	}
+	raw, err := mr.Get("test:a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored testValue
+	if json.Unmarshal([]byte(raw), &stored) != nil || stored != want {
+		t.Errorf("got %q stored, want the JSON of %v", raw, want)
+	}
+
+	got, ok := r.Get(ctx, "a")
+	if !ok || got != want {
+		t.Errorf("Get() = %v, %v, want %v", got, ok, want)
+	}
+
+	// expired by the server
+	mr.FastForward(conf.TTL)
+	if _, ok := r.Get(ctx, "a"); ok {
+		t.Error("expired entry is returned")
+	}
+}
+
+func TestRedisDelete(t *testing.T) {
+	ctx := context.Background()
+	mr, conf := testRedisConf(t)
+	r := newTestRedis[testValue](t, conf)
+
+	r.Set(ctx, "a", testValue{Name: "a"})
+	r.Delete(ctx, "a")
+	if mr.Exists("test:a") {
+		t.Error("deleted entry is still in the server")
+	}
+	if _, ok := r.Get(ctx, "a"); ok {
+		t.Error("deleted entry is returned")
+	}
+}
+
+func TestRedisSharedPrefix(t *testing.T) {
+	ctx := context.Background()
+	_, conf := testRedisConf(t)
+	a := newTestRedis[testValue](t, conf)
+	b := newTestRedis[testValue](t, conf)
+	conf.Redis.KeyPrefix = "other:"
+	other := newTestRedis[testValue](t, conf)
+
+	a.Set(ctx, "k", testValue{Name: "k"})
+	if _, ok := b.Get(ctx, "k"); !ok {
+		t.Error("the same prefix should share the entries")
+	}
+	if _, ok := other.Get(ctx, "k"); ok {
+		t.Error("another prefix shouldn't see the entries")
+	}
+}
+
+func TestRedisBrokenEntry(t *testing.T) {
+	ctx := context.Background()
+	mr, conf := testRedisConf(t)
+	r := newTestRedis[testValue](t, conf)
+
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"not JSON", "not json"},
+		{"wrong field type", `{"Name": 1}`},
+		{"truncated", `{"Name": "a"`},
+		{"empty", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := mr.Set("test:broken", tt.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, ok := r.Get(ctx, "broken"); ok {
+				t.Errorf("Get() = %v for %q, want a miss", got, tt.raw)
+			}
+		})
+	}
+
+	// not a string value
+	mr.Del("test:broken")
+	mr.Lpush("test:broken", "a")
+	if got, ok := r.Get(ctx, "broken"); ok {
+		t.Errorf("Get() = %v for a list, want a miss", got)
+	}
+}
+
+func TestRedisUnavailable(t *testing.T) {
+	ctx := context.Background()
+	mr, conf := testRedisConf(t)
+	conf.Redis.Timeout = 100 * time.Millisecond
+	r := newTestRedis[testValue](t, conf)
+	r.Set(ctx, "a", testValue{Name: "a"})
+
+	// errors are misses
+	mr.SetError("LOADING")
+	if _, ok := r.Get(ctx, "a"); ok {
+		t.Error("Get() hits with a server error")
+	}
+	mr.SetError("")
+
+	mr.Close()
+	if _, ok := r.Get(ctx, "a"); ok {
+		t.Error("Get() hits with a closed server")
+	}
+	r.Set(ctx, "b", testValue{})
+	r.Delete(ctx, "a")
+}
+
+func TestRedisPing(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(mr *miniredis.Miniredis, conf *config.ConfigCache)
+	}{
+		{"closed server", func(mr *miniredis.Miniredis, conf *config.ConfigCache) {
+			mr.Close()
+		}},
+		{"wrong password", func(mr *miniredis.Miniredis, conf *config.ConfigCache) {
+			mr.RequireAuth("secret")
+			conf.Redis.Password = "wrong"
+		}},
+		{"no password", func(mr *miniredis.Miniredis, conf *config.ConfigCache) {
+			mr.RequireAuth("secret")
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr, conf := testRedisConf(t)
+			conf.Redis.Timeout = 100 * time.Millisecond
+			tt.setup(mr, &conf)
+
+			_, err := New[testValue](conf)
+			if err == nil {
+				t.Fatal("New() should fail")
+			}
+			if !strings.Contains(err.Error(), "cache.redis.address") {
+				t.Errorf("New() error = %v, want the config option in it", err)
+			}
+		})
+	}
+
+	mr, conf := testRedisConf(t)
+	mr.RequireAuth("secret")
+	conf.Redis.Password = "secret"
+	backend, err := New[testValue](conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.Close()
+}
+
+func TestTiered(t *testing.T) {
+	ctx := context.Background()
+	mr, conf := testRedisConf(t)
+	conf.Redis.LocalTTL = time.Minute
+
+	backend, err := New[testValue](conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+	tier, ok := backend.(*tiered[testValue])
+	if !ok {
+		t.Fatalf("got %T, want the tiered backend", backend)
+	}
+	local := tier.local.(*memory[testValue])
+	if local.ttl != conf.Redis.LocalTTL {
+		t.Errorf("got local TTL %v, want %v", local.ttl, conf.Redis.LocalTTL)
+	}
+
+	// written by another instance
+	want := testValue{Name: "shared", N: 1}
+	data, _ := json.Marshal(want)
+	mr.Set("test:shared", string(data))
+
+	got, ok := backend.Get(ctx, "shared")
+	if !ok || got != want {
+		t.Fatalf("Get() = %v, %v, want %v", got, ok, want)
+	}
+	// the shared hit fills the local tier
+	if got, ok := local.Get(ctx, "shared"); !ok || got != want {
+		t.Errorf("local Get() = %v, %v, want %v", got, ok, want)
+	}
+	mr.Del("test:shared")
+	if _, ok := backend.Get(ctx, "shared"); !ok {
+		t.Error("the local tier isn't used")
+	}
+
+	// both tiers are written and deleted
+	backend.Set(ctx, "a", want)
+	if !mr.Exists("test:a") || local.Len() != 2 {
+		t.Error("Set() doesn't write both tiers")
+	}
+	backend.Delete(ctx, "a")
+	if _, ok := backend.Get(ctx, "a"); ok || mr.Exists("test:a") {
+		t.Error("Delete() doesn't remove from both tiers")
+	}
+}
+
+func TestTieredLocalTTL(t *testing.T) {
+	tests := []struct {
+		name      string
+		ttl       time.Duration
+		localTTL  time.Duration
+		wantTier  bool
+		wantLocal time.Duration
+	}{
+		{"no local tier", time.Hour, 0, false, 0},
+		{"local TTL", time.Hour, time.Minute, true, time.Minute},
+		{"capped by TTL", time.Minute, time.Hour, true, time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, conf := testRedisConf(t)
+			conf.TTL = tt.ttl
+			conf.Redis.LocalTTL = tt.localTTL
+
+			backend, err := New[testValue](conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer backend.Close()
+
+			tier, ok := backend.(*tiered[testValue])
+			if ok != tt.wantTier {
+				t.Fatalf("got %T, want tiered %v", backend, tt.wantTier)
+			}
+			if !ok {
+				return
+			}
+			if ttl := tier.local.(*memory[testValue]).ttl; ttl != tt.wantLocal {
+				t.Errorf("got local TTL %v, want %v", ttl, tt.wantLocal)
+			}
+		})
+	}
+}
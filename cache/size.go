@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"reflect"
+)
+
+// Bookkeeping of an entry: the list element, the map slot and the entry struct itself.
+const entryOverhead = 128
+
+// Approximate memory usage of value, including the memory its strings, slices and pointers refer to.
+// It's only used for the memory cap, so it doesn't need to be exact.
+func sizeOf(value any) int64 {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() {
+		return entryOverhead
+	}
+	return entryOverhead + int64(v.Type().Size()) + sizeOfRefs(v)
+}
+
+// Size of the memory referred by v, not including v itself.
+func sizeOfRefs(v reflect.Value) int64 {
+	switch v.Kind() {
+	case reflect.String:
+		return int64(v.Len())
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		elem := v.Elem()
+		return int64(elem.Type().Size()) + sizeOfRefs(elem)
+	case reflect.Slice:
+		if v.IsNil() {
+			return 0
+		}
+		size := int64(v.Cap()) * int64(v.Type().Elem().Size())
+		for i := range v.Len() {
+			size += sizeOfRefs(v.Index(i))
+		}
+		return size
+	case reflect.Array:
+		var size int64
+		for i := range v.Len() {
+			size += sizeOfRefs(v.Index(i))
+		}
+		return size
+	case reflect.Struct:
+		var size int64
+		for i := range v.NumField() {
+			size += sizeOfRefs(v.Field(i))
+		}
+		return size
+	case reflect.Map:
+		var size int64
+		iter := v.MapRange()
+		for iter.Next() {
+			key, value := iter.Key(), iter.Value()
+			size += int64(key.Type().Size()+value.Type().Size()) + sizeOfRefs(key) + sizeOfRefs(value)
+		}
+		return size
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	C "github.com/SourLemonJuice/ipapi-agent/constant"
+)
+
+type ConfigCache struct {
+	Enabled         bool             `toml:"enabled"`
+	Backend         string           `toml:"backend"`
+	TTL             time.Duration    `toml:"ttl"`
+	CleanupInterval time.Duration    `toml:"cleanup_interval"`
+	MaxEntries      int              `toml:"max_entries"`
+	MaxMemoryMB     int              `toml:"max_memory_mb"`
+	DiskPath        string           `toml:"disk_path"`
+	Redis           ConfigCacheRedis `toml:"redis"`
+}
+
+var DefaultCache = ConfigCache{
+	Enabled:         true,
+	Backend:         C.CacheBackendMemory,
+	TTL:             6 * time.Hour,
+	CleanupInterval: 30 * time.Minute,
+	MaxEntries:      100000,
+	MaxMemoryMB:     64,
+	DiskPath:        "",
+	Redis:           DefaultCacheRedis,
+}
+
+func (cache *ConfigCache) validate() error {
+	switch cache.Backend {
+	case C.CacheBackendMemory:
+	case C.CacheBackendRedis:
+		if len(cache.DiskPath) > 0 {
+			return errors.New("cache.disk_path can't be used with the redis backend")
+		}
+		err := cache.Redis.validate()
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown cache.backend '%v'", cache.Backend)
+	}
+
+	if cache.TTL <= 0 {
+		return errors.New("cache.ttl should be positive")
+	}
+	if cache.CleanupInterval <= 0 {
+		return errors.New("cache.cleanup_interval should be positive")
+	}
+	// 0 means no limit
+	if cache.MaxEntries < 0 {
+		return errors.New("cache.max_entries is negative")
+	}
+	if cache.MaxMemoryMB < 0 {
+		return errors.New("cache.max_memory_mb is negative")
+	}
+
+	return nil
+}
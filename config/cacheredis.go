@@ -0,0 +1,44 @@
+package config
+
+import (
+	"errors"
+	"time"
+)
+
+type ConfigCacheRedis struct {
+	Address   string        `toml:"address"`
+	Username  string        `toml:"username"`
+	Password  string        `toml:"password"`
+	DB        int           `toml:"db"`
+	KeyPrefix string        `toml:"key_prefix"`
+	Timeout   time.Duration `toml:"timeout"`
+	LocalTTL  time.Duration `toml:"local_ttl"`
+}
+
+var DefaultCacheRedis = ConfigCacheRedis{
+	Address:   "127.0.0.1:6379",
+	Username:  "",
+	Password:  "",
+	DB:        0,
+	KeyPrefix: "ipapi:query:",
+	Timeout:   500 * time.Millisecond,
+	LocalTTL:  1 * time.Minute,
+}
+
+func (redis *ConfigCacheRedis) validate() error {
+	if len(redis.Address) == 0 {
+		return errors.New("cache.redis.address is empty")
+	}
+	if redis.DB < 0 {
+		return errors.New("cache.redis.db is negative")
+	}
+	if redis.Timeout <= 0 {
+		return errors.New("cache.redis.timeout should be positive")
+	}
+	// 0 disables the local tier
+	if redis.LocalTTL < 0 {
+		return errors.New("cache.redis.local_ttl is negative")
+	}
+
+	return nil
+}
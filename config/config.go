@@ -7,12 +7,18 @@ import (
 )
 
 type Config struct {
-	Listen         string         `toml:"listen"`
-	Port           uint16         `toml:"port"`
-	TrustedProxies []string       `toml:"trusted_proxies"`
-	Upstream       ConfigUpstream `toml:"upstream"`
-	Domain         ConfigDomain   `toml:"domain"`
-	Dev            ConfigDev      `toml:"dev"`
+	Listen         string           `toml:"listen"`
+	Port           uint16           `toml:"port"`
+	TrustedProxies []string         `toml:"trusted_proxies"`
+	Upstream       ConfigUpstream   `toml:"upstream"`
+	Domain         ConfigDomain     `toml:"domain"`
+	Special        ConfigSpecial    `toml:"special"`
+	Privacy        ConfigPrivacy    `toml:"privacy"`
+	RDNS           ConfigRDNS       `toml:"rdns"`
+	Cache          ConfigCache      `toml:"cache"`
+	Text           ConfigText       `toml:"text"`
+	Override       []ConfigOverride `toml:"override"`
+	Dev            ConfigDev        `toml:"dev"`
 }
 
 func Default() Config {
@@ -21,6 +27,11 @@ func Default() Config {
 		Port:           8080,
 		TrustedProxies: []string{"127.0.0.1", "::1"},
 		Domain:         DefaultDomain,
+		Special:        DefaultSpecial,
+		Privacy:        DefaultPrivacy,
+		RDNS:           DefaultRDNS,
+		Cache:          DefaultCache,
+		Text:           DefaultText,
 		Upstream:       DefaultUpstream,
 		Dev:            DefaultDev,
 	}
@@ -58,6 +69,28 @@ func (conf *Config) validate() error {
 		return err
 	}
 
+	err = conf.RDNS.validate()
+	if err != nil {
+		return err
+	}
+
+	err = conf.Cache.validate()
+	if err != nil {
+		return err
+	}
+
+	err = conf.Text.validate()
+	if err != nil {
+		return err
+	}
+
+	for i := range conf.Override {
+		err = conf.Override[i].validate()
+		if err != nil {
+			return fmt.Errorf("override[%v]: %w", i, err)
+		}
+	}
+
 	err = conf.Dev.validate()
 	if err != nil {
 		return err
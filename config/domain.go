@@ -1,16 +1,81 @@
 package config
 
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"golang.org/x/net/idna"
+
+	C "github.com/SourLemonJuice/ipapi-agent/constant"
+)
+
 type ConfigDomain struct {
-	Enabled     bool     `toml:"enabled"`
-	BlockSuffix []string `toml:"block_suffix"`
+	Enabled           bool               `toml:"enabled"`
+	Mode              string             `toml:"mode"`
+	Rules             []ConfigDomainRule `toml:"rule"`
+	BlockSuffix       []string           `toml:"block_suffix"`
+	LookupConcurrency int                `toml:"lookup_concurrency"`
+	PreferFamily      int                `toml:"prefer_family"`
+	RejectAnySpecial  bool               `toml:"reject_any_special"`
+	MaxAnswers        int                `toml:"max_answers"`
+	Resolver          ConfigResolver     `toml:"resolver"`
 }
 
 var DefaultDomain = ConfigDomain{
-	Enabled:     true,
-	BlockSuffix: nil,
+	Enabled:           true,
+	Mode:              C.DomainModeDenylist,
+	Rules:             nil,
+	BlockSuffix:       nil,
+	LookupConcurrency: 4,
+	PreferFamily:      4,
+	RejectAnySpecial:  true,
+	MaxAnswers:        16,
+	Resolver:          DefaultResolver,
 }
 
 func (domain *ConfigDomain) validate() error {
+	switch domain.Mode {
+	case C.DomainModeDenylist:
+	case C.DomainModeAllowlist:
+	default:
+		return fmt.Errorf("domain.mode has unknown type '%v'", domain.Mode)
+	}
+
+	for i := range domain.Rules {
+		err := domain.Rules[i].validate()
+		if err != nil {
+			return fmt.Errorf("domain.rule[%v]: %w", i, err)
+		}
+	}
+
+	if domain.LookupConcurrency < 1 {
+		return errors.New("domain.lookup_concurrency should be at least 1")
+	}
+
+	// 0 means no preference
+	if !slices.Contains([]int{0, 4, 6}, domain.PreferFamily) {
+		return errors.New("domain.prefer_family should be 4, 6 or 0")
+	}
+
+	if domain.MaxAnswers < 1 {
+		return errors.New("domain.max_answers should be at least 1")
+	}
+
+	err := domain.Resolver.validate()
+	if err != nil {
+		return err
+	}
+
+	// the suffix check uses the ASCII form(punycode), like "xn--fiqs8s" for "中国"
+	for i, suffix := range domain.BlockSuffix {
+		ascii, err := idna.Lookup.ToASCII(suffix)
+		if err != nil {
+			return fmt.Errorf("bad domain.block_suffix '%v': %w", suffix, err)
+		}
+		domain.BlockSuffix[i] = ascii
+	}
+
 	// block some reserved TLDs
 	// you may want to block .lan TLD with config file, because that's not a part of any standard.
 	// https://en.wikipedia.org/wiki/Special-use_domain_name
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+
+	C "github.com/SourLemonJuice/ipapi-agent/constant"
+)
+
+// One [[domain.rule]] table, only one of Domain, Wildcard and Regex can be set.
+type ConfigDomainRule struct {
+	Action   string `toml:"action"`
+	Domain   string `toml:"domain"`
+	Wildcard string `toml:"wildcard"`
+	Regex    string `toml:"regex"`
+}
+
+func (rule *ConfigDomainRule) validate() error {
+	switch rule.Action {
+	case C.DomainRuleAllow:
+	case C.DomainRuleDeny:
+	default:
+		return fmt.Errorf("unknown action '%v'", rule.Action)
+	}
+
+	count := 0
+	for _, v := range []string{rule.Domain, rule.Wildcard, rule.Regex} {
+		if len(v) > 0 {
+			count++
+		}
+	}
+	if count != 1 {
+		return errors.New("one and only one of domain, wildcard and regex should be set")
+	}
+
+	if len(rule.Wildcard) > 0 {
+		_, err := path.Match(rule.Wildcard, "")
+		if err != nil {
+			return fmt.Errorf("bad wildcard '%v': %w", rule.Wildcard, err)
+		}
+	}
+
+	if len(rule.Regex) > 0 {
+		_, err := regexp.Compile(rule.Regex)
+		if err != nil {
+			return fmt.Errorf("bad regex: %w", err)
+		}
+	}
+
+	return nil
+}
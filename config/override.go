@@ -0,0 +1,53 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"time"
+)
+
+// One [[override]] table, the fixed response for some CIDRs.
+type ConfigOverride struct {
+	CIDR        []string `toml:"cidr"`
+	DataSource  string   `toml:"data_source"`
+	Hostname    string   `toml:"hostname"`
+	CountryCode string   `toml:"country_code"`
+	Region      string   `toml:"region"`
+	RegionCode  string   `toml:"region_code"`
+	Timezone    string   `toml:"timezone"`
+	Org         string   `toml:"org"`
+	ISP         string   `toml:"isp"`
+	ASN         string   `toml:"asn"`
+	ASName      string   `toml:"as_name"`
+}
+
+var asnRegexp = regexp.MustCompile(`^AS\d+$`)
+
+func (override *ConfigOverride) validate() error {
+	if len(override.CIDR) == 0 {
+		return errors.New("cidr is empty")
+	}
+	for _, cidr := range override.CIDR {
+		_, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return fmt.Errorf("bad cidr: %w", err)
+		}
+	}
+
+	if len(override.DataSource) == 0 {
+		override.DataSource = "override"
+	}
+
+	_, err := time.LoadLocation(override.Timezone)
+	if err != nil {
+		return fmt.Errorf("bad timezone: %w", err)
+	}
+
+	if len(override.ASN) > 0 && !asnRegexp.MatchString(override.ASN) {
+		return fmt.Errorf("bad asn '%v', should be like AS13335", override.ASN)
+	}
+
+	return nil
+}
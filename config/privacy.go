@@ -0,0 +1,11 @@
+package config
+
+type ConfigPrivacy struct {
+	TorList []string `toml:"tor_list"`
+	VPNList []string `toml:"vpn_list"`
+}
+
+var DefaultPrivacy = ConfigPrivacy{
+	TorList: nil,
+	VPNList: nil,
+}
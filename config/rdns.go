@@ -0,0 +1,24 @@
+package config
+
+import (
+	"errors"
+	"time"
+)
+
+type ConfigRDNS struct {
+	Enabled bool          `toml:"enabled"`
+	Timeout time.Duration `toml:"timeout"`
+}
+
+var DefaultRDNS = ConfigRDNS{
+	Enabled: true,
+	Timeout: 1 * time.Second,
+}
+
+func (rdns *ConfigRDNS) validate() error {
+	if rdns.Timeout <= 0 {
+		return errors.New("rdns.timeout too short")
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	C "github.com/SourLemonJuice/ipapi-agent/constant"
+)
+
+type ConfigResolver struct {
+	Protocol        string        `toml:"protocol"`
+	Servers         []string      `toml:"servers"`
+	DisableECS      bool          `toml:"disable_ecs"`
+	CacheMaxTTL     time.Duration `toml:"cache_max_ttl"`
+	CacheMaxEntries int           `toml:"cache_max_entries"`
+	SystemTTL       time.Duration `toml:"system_ttl"`
+}
+
+var DefaultResolver = ConfigResolver{
+	Protocol:        C.ResolverProtocolSystem,
+	Servers:         nil,
+	DisableECS:      false,
+	CacheMaxTTL:     10 * time.Minute,
+	CacheMaxEntries: 10000,
+	SystemTTL:       1 * time.Minute,
+}
+
+func (resolver *ConfigResolver) validate() error {
+	if resolver.CacheMaxTTL < 0 {
+		return errors.New("domain.resolver.cache_max_ttl is negative")
+	}
+	if resolver.CacheMaxEntries < 0 {
+		return errors.New("domain.resolver.cache_max_entries is negative")
+	}
+	if resolver.SystemTTL <= 0 {
+		return errors.New("domain.resolver.system_ttl should be positive")
+	}
+
+	var defPort string
+	switch resolver.Protocol {
+	case C.ResolverProtocolSystem:
+		if len(resolver.Servers) > 0 {
+			return errors.New("domain.resolver.servers can't be used with the system resolver")
+		}
+		if resolver.DisableECS {
+			return errors.New("domain.resolver.disable_ecs can't be used with the system resolver")
+		}
+		return nil
+	case C.ResolverProtocolUDP, C.ResolverProtocolTCP:
+		defPort = "53"
+	case C.ResolverProtocolTLS:
+		defPort = "853"
+	case C.ResolverProtocolHTTPS:
+	default:
+		return fmt.Errorf("unknown domain.resolver.protocol '%v'", resolver.Protocol)
+	}
+
+	if len(resolver.Servers) == 0 {
+		return errors.New("domain.resolver.servers is empty")
+	}
+
+	for i, server := range resolver.Servers {
+		if resolver.Protocol == C.ResolverProtocolHTTPS {
+			u, err := url.Parse(server)
+			if err != nil || u.Scheme != "https" || u.Host == "" {
+				return fmt.Errorf("domain.resolver.servers '%v' should be a https URL", server)
+			}
+			continue
+		}
+
+		// append the default port if not set, like "1.1.1.1" or "[2606:4700:4700::1111]"
+		_, _, err := net.SplitHostPort(server)
+		if err != nil {
+			host := server
+			if len(host) > 2 && host[0] == '[' && host[len(host)-1] == ']' {
+				host = host[1 : len(host)-1]
+			}
+			resolver.Servers[i] = net.JoinHostPort(host, defPort)
+		}
+	}
+
+	return nil
+}
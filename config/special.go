@@ -0,0 +1,13 @@
+package config
+
+type ConfigSpecial struct {
+	Allow            []string `toml:"allow"`
+	Deny             []string `toml:"deny"`
+	ReservedResponse bool     `toml:"reserved_response"`
+}
+
+var DefaultSpecial = ConfigSpecial{
+	Allow:            nil,
+	Deny:             nil,
+	ReservedResponse: false,
+}
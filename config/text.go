@@ -0,0 +1,31 @@
+package config
+
+import "errors"
+
+type ConfigText struct {
+	Template            string   `toml:"template"`
+	TemplateFile        string   `toml:"template_file"`
+	FailureTemplate     string   `toml:"failure_template"`
+	FailureTemplateFile string   `toml:"failure_template_file"`
+	ColorUserAgents     []string `toml:"color_user_agents"`
+}
+
+var DefaultText = ConfigText{
+	Template:            "",
+	TemplateFile:        "",
+	FailureTemplate:     "",
+	FailureTemplateFile: "",
+	ColorUserAgents:     []string{"curl/*", "HTTPie/*", "Wget/*", "xh/*", "*PowerShell/*"},
+}
+
+func (text *ConfigText) validate() error {
+	if len(text.Template) > 0 && len(text.TemplateFile) > 0 {
+		return errors.New("text.template and text.template_file can't be used together")
+	}
+
+	if len(text.FailureTemplate) > 0 && len(text.FailureTemplateFile) > 0 {
+		return errors.New("text.failure_template and text.failure_template_file can't be used together")
+	}
+
+	return nil
+}
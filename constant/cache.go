@@ -0,0 +1,6 @@
+package constant
+
+const (
+	CacheBackendMemory = "memory"
+	CacheBackendRedis  = "redis"
+)
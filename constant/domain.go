@@ -0,0 +1,11 @@
+package constant
+
+const (
+	DomainModeDenylist  = "denylist"
+	DomainModeAllowlist = "allowlist"
+)
+
+const (
+	DomainRuleAllow = "allow"
+	DomainRuleDeny  = "deny"
+)
@@ -0,0 +1,9 @@
+package constant
+
+const (
+	ResolverProtocolSystem = "system"
+	ResolverProtocolUDP    = "udp"
+	ResolverProtocolTCP    = "tcp"
+	ResolverProtocolTLS    = "tls"
+	ResolverProtocolHTTPS  = "https"
+)
@@ -3,4 +3,6 @@ package constant
 const (
 	ResponseStatusSuccess = "success"
 	ResponseStatusFailure = "failure"
+	// the address is special, so it's not queried
+	ResponseStatusRejected = "rejected"
 )
@@ -0,0 +1,122 @@
+package domainrule
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/idna"
+
+	"github.com/SourLemonJuice/ipapi-agent/config"
+	C "github.com/SourLemonJuice/ipapi-agent/constant"
+	"github.com/SourLemonJuice/ipapi-agent/debug"
+)
+
+type Rule struct {
+	Index   int    // position in config, start from 0
+	Action  string // allow or deny
+	Kind    string // domain, wildcard or regex
+	Pattern string // as written in config
+	match   func(domain string) bool
+}
+
+func (rule Rule) String() string {
+	return fmt.Sprintf("#%v %v %v %q", rule.Index, rule.Action, rule.Kind, rule.Pattern)
+}
+
+var (
+	mode  string
+	rules []Rule
+)
+
+// Compile the rules in config, the config should be validated already.
+func Load(conf config.ConfigDomain) error {
+	mode = conf.Mode
+	rules = nil
+
+	for i, v := range conf.Rules {
+		rule := Rule{
+			Index:  i,
+			Action: v.Action,
+		}
+
+		switch {
+		case len(v.Domain) > 0:
+			rule.Kind, rule.Pattern = "domain", v.Domain
+			ascii, err := toASCII(v.Domain)
+			if err != nil {
+				return fmt.Errorf("domain.rule[%v]: %w", i, err)
+			}
+			rule.match = func(domain string) bool {
+				return domain == ascii
+			}
+		case len(v.Wildcard) > 0:
+			rule.Kind, rule.Pattern = "wildcard", v.Wildcard
+			ascii, err := toASCII(v.Wildcard)
+			if err != nil {
+				return fmt.Errorf("domain.rule[%v]: %w", i, err)
+			}
+			rule.match = func(domain string) bool {
+				// "*" also matches dots, domains have no "/" that path.Match cares about
+				matched, _ := path.Match(ascii, domain)
+				return matched
+			}
+		case len(v.Regex) > 0:
+			rule.Kind, rule.Pattern = "regex", v.Regex
+			re := regexp.MustCompile(v.Regex)
+			rule.match = re.MatchString
+		}
+
+		rules = append(rules, rule)
+	}
+
+	debug.Logger.Printf("Domain rules loaded, mode: %v, rules: %v", mode, len(rules))
+	return nil
+}
+
+// Convert each label to the ASCII form, except the wildcard ones like "*" or "ex*".
+func toASCII(pattern string) (string, error) {
+	labels := strings.Split(strings.TrimSuffix(pattern, "."), ".")
+	for i, label := range labels {
+		if strings.ContainsAny(label, "*?[") {
+			labels[i] = strings.ToLower(label)
+			continue
+		}
+
+		ascii, err := labelProfile.ToASCII(label)
+		if err == nil && strings.ContainsFunc(ascii, notLabelRune) {
+			err = fmt.Errorf("disallowed rune in %q", ascii)
+		}
+		if err != nil {
+			return "", fmt.Errorf("bad label '%v': %w", label, err)
+		}
+		labels[i] = ascii
+	}
+
+	return strings.Join(labels, "."), nil
+}
+
+// Like idna.Lookup, but the underscore is allowed the same as in domain queries.
+var labelProfile = idna.New(idna.MapForLookup(), idna.StrictDomainName(false), idna.BidiRule())
+
+func notLabelRune(r rune) bool {
+	return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_')
+}
+
+// Return whether the domain(ASCII form) can be resolved, and the first matched rule.
+// If nothing matched, the mode decides.
+func Check(domain string) (bool, *Rule) {
+	for _, rule := range rules {
+		if rule.match(domain) {
+			return rule.Action == C.DomainRuleAllow, &rule
+		}
+	}
+
+	return mode != C.DomainModeAllowlist, nil
+}
+
+// The mode of the loaded rules.
+func Mode() string {
+	return mode
+}
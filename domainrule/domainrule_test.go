@@ -0,0 +1,115 @@
+package domainrule
+
+import (
+	"testing"
+
+	"github.com/SourLemonJuice/ipapi-agent/config"
+	C "github.com/SourLemonJuice/ipapi-agent/constant"
+)
+
+func TestCheck(t *testing.T) {
+	rules := []config.ConfigDomainRule{
+		{Action: C.DomainRuleAllow, Domain: "ok.corp.example"},
+		{Action: C.DomainRuleDeny, Wildcard: "*.corp.example"},
+		{Action: C.DomainRuleDeny, Regex: `(^|\.)ads[0-9]*\.`},
+		{Action: C.DomainRuleAllow, Wildcard: "*.example"},
+		{Action: C.DomainRuleDeny, Domain: "Bücher.example"},
+		{Action: C.DomainRuleDeny, Wildcard: "cdn?.*.net"},
+	}
+
+	tests := []struct {
+		mode      string
+		domain    string
+		want      bool
+		wantIndex int // -1 if no rule matched
+	}{
+		// the first matched rule wins
+		{C.DomainModeDenylist, "ok.corp.example", true, 0},
+		{C.DomainModeDenylist, "bad.corp.example", false, 1},
+		// "*" also matches dots
+		{C.DomainModeDenylist, "a.b.corp.example", false, 1},
+		// but needs a label before the suffix
+		{C.DomainModeDenylist, "corp.example", true, 3},
+		{C.DomainModeDenylist, "notcorp.example", true, 3},
+		{C.DomainModeDenylist, "ads.example", false, 2},
+		{C.DomainModeDenylist, "x.ads12.example.org", false, 2},
+		{C.DomainModeDenylist, "loads.example", true, 3},
+		// matched by the wildcard before the domain rule
+		{C.DomainModeDenylist, "xn--bcher-kva.example", true, 3},
+		{C.DomainModeDenylist, "cdn1.img.net", false, 5},
+		{C.DomainModeDenylist, "my_host.corp.example", false, 1},
+		{C.DomainModeDenylist, "cdn12.img.net", true, -1},
+		// no match, the mode decides
+		{C.DomainModeDenylist, "example.org", true, -1},
+		{C.DomainModeAllowlist, "example.org", false, -1},
+		{C.DomainModeAllowlist, "www.example", true, 3},
+		{C.DomainModeAllowlist, "bad.corp.example", false, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode+" "+tt.domain, func(t *testing.T) {
+			err := Load(config.ConfigDomain{Mode: tt.mode, Rules: rules})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, rule := Check(tt.domain)
+			if got != tt.want {
+				t.Errorf("Check(%q) = %v, want %v", tt.domain, got, tt.want)
+			}
+
+			index := -1
+			if rule != nil {
+				index = rule.Index
+			}
+			if index != tt.wantIndex {
+				t.Errorf("Check(%q) matched rule %v, want %v", tt.domain, rule, tt.wantIndex)
+			}
+		})
+	}
+}
+
+func TestToASCII(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+		wantErr bool
+	}{
+		{"example.com", "example.com", false},
+		{"Example.COM.", "example.com", false},
+		{"bücher.example", "xn--bcher-kva.example", false},
+		{"*.Bücher.example", "*.xn--bcher-kva.example", false},
+		{"EX*.example", "ex*.example", false},
+		{"_dmarc.Example.com", "_dmarc.example.com", false},
+		{"exa mple.com", "", true},
+		{"exa!mple.com", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			got, err := toASCII(tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("toASCII(%q) error = %v, wantErr %v", tt.pattern, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("toASCII(%q) = %q, want %q", tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleString(t *testing.T) {
+	err := Load(config.ConfigDomain{
+		Mode:  C.DomainModeDenylist,
+		Rules: []config.ConfigDomainRule{{Action: C.DomainRuleDeny, Wildcard: "*.corp.example"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, rule := Check("a.corp.example")
+	want := `#0 deny wildcard "*.corp.example"`
+	if rule == nil || rule.String() != want {
+		t.Errorf("got rule %v, want %v", rule, want)
+	}
+}
@@ -0,0 +1,54 @@
+package geo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/biter777/countries"
+
+	"github.com/SourLemonJuice/ipapi-agent/response"
+)
+
+// Fill the country related fields of the Query with the local dataset, based on its CountryCode.
+// The country name will be replaced too, so every upstream returns the same name for the same country.
+func FillCountry(resp *response.Query) {
+	resp.CountryCode = strings.ToUpper(resp.CountryCode)
+	if len(resp.CountryCode) != 2 {
+		return
+	}
+
+	country := countries.ByName(resp.CountryCode)
+	if !country.IsValid() {
+		return
+	}
+
+	resp.Country = countryName(country)
+	resp.CountryAlpha3 = country.Alpha3()
+	resp.CountryNumeric = fmt.Sprintf("%03d", int(country))
+	resp.CountryFlag = country.Emoji()
+	resp.Capital = country.Capital().String()
+	resp.Currency = country.Currency().Alpha()
+	resp.TLD = country.Domain().String()
+	resp.Subregion = subregion[resp.CountryCode]
+
+	callCodes := country.CallCodes()
+	if len(callCodes) > 0 {
+		resp.CallingCode = callCodes[0].String()
+	}
+}
+
+// Return the common name of a country, without the remark in parentheses at the end:
+// "Hong Kong (Special Administrative Region of China)" -> "Hong Kong",
+// but "Cocos (Keeling) Islands" is kept.
+func countryName(country countries.CountryCode) string {
+	name := country.String()
+	if !strings.HasSuffix(name, ")") {
+		return name
+	}
+
+	i := strings.LastIndex(name, " (")
+	if i < 0 {
+		return name
+	}
+	return name[:i]
+}
@@ -0,0 +1,59 @@
+package geo
+
+import (
+	"testing"
+
+	"github.com/biter777/countries"
+
+	"github.com/SourLemonJuice/ipapi-agent/response"
+)
+
+func TestCountryName(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"JP", "Japan"},
+		{"DE", "Germany"},
+		// the parentheses in the middle are a part of the name
+		{"CC", "Cocos (Keeling) Islands"},
+		{"HK", "Hong Kong"},
+		{"MO", "Macau"},
+		{"TW", "Taiwan"},
+		{"IR", "Iran"},
+		{"FK", "Falkland Islands"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			got := countryName(countries.ByName(tt.code))
+			if got != tt.want {
+				t.Errorf("countryName(%v) = %q, want %q", tt.code, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFillCountry(t *testing.T) {
+	resp := response.Query{CountryCode: "cc", Country: "Cocos Islands"}
+	FillCountry(&resp)
+
+	want := response.Query{
+		CountryCode:    "CC",
+		Country:        "Cocos (Keeling) Islands",
+		CountryAlpha3:  "CCK",
+		CountryNumeric: "166",
+		Subregion:      "Australia and New Zealand",
+	}
+	if resp.CountryCode != want.CountryCode || resp.Country != want.Country || resp.CountryAlpha3 != want.CountryAlpha3 ||
+		resp.CountryNumeric != want.CountryNumeric || resp.Subregion != want.Subregion {
+		t.Errorf("FillCountry() = %+v, want %+v", resp, want)
+	}
+
+	// unknown codes are kept as is
+	resp = response.Query{CountryCode: "zz", Country: "Somewhere"}
+	FillCountry(&resp)
+	if resp.CountryCode != "ZZ" || resp.Country != "Somewhere" || resp.CountryAlpha3 != "" {
+		t.Errorf("FillCountry() with an unknown code = %+v", resp)
+	}
+}
@@ -0,0 +1,143 @@
+package geo
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/biter777/countries"
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/SourLemonJuice/ipapi-agent/response"
+)
+
+// Normalized region name to ISO 3166-2 code, key format is "<country code>/<normalized name>".
+var regionIndex = map[string]string{}
+
+// Words that only describe the type of the subdivision, ignored when comparing names.
+var regionTypeWords = []string{
+	"city", "county", "district", "lan", "municipality", "oblast", "prefecture", "province", "region", "sheng", "shi", "state", "zizhiqu",
+}
+
+func init() {
+	// the exact names first, so a name without type words can't shadow another region,
+	// like "Moscow Oblast" and "Moscow"
+	for _, stripType := range []bool{false, true} {
+		// aliases take precedence over the names in dataset
+		for code, names := range regionAlias {
+			for _, name := range names {
+				addRegionName(code, name, stripType)
+			}
+		}
+
+		all := countries.AllSubdivisions()
+		// full names first, so the alternative names can't shadow another subdivision
+		for _, sub := range all {
+			addRegionName(string(sub), sub.String(), stripType)
+		}
+		for _, sub := range all {
+			for _, name := range alternativeNames(sub.String()) {
+				addRegionName(string(sub), name, stripType)
+			}
+		}
+	}
+}
+
+// Fill the RegionCode of the Query with an ISO 3166-2 subdivision code.
+// A code provided by the upstream will be validated first, if it's not usable, find the code by region name.
+// RegionCode will be empty if nothing was found.
+func FillRegion(resp *response.Query) {
+	code := strings.ToUpper(resp.RegionCode)
+	if strings.HasPrefix(code, resp.CountryCode+"-") && countries.SubdivisionCode(code).IsValid() {
+		resp.RegionCode = code
+		return
+	}
+
+	resp.RegionCode = regionCodeByName(resp.CountryCode, resp.Region)
+}
+
+func regionCodeByName(countryCode string, name string) string {
+	if len(countryCode) != 2 || len(name) == 0 {
+		return ""
+	}
+
+	code, ok := regionIndex[countryCode+"/"+normalizeRegionName(name, false)]
+	if ok {
+		return code
+	}
+
+	code, ok = regionIndex[countryCode+"/"+normalizeRegionName(name, true)]
+	if ok {
+		return code
+	}
+
+	return ""
+}
+
+// Register the normalized name, existing keys won't be replaced.
+func addRegionName(code string, name string, stripType bool) {
+	country, _, found := strings.Cut(code, "-")
+	if !found {
+		return
+	}
+
+	key := country + "/" + normalizeRegionName(name, stripType)
+	if _, exist := regionIndex[key]; !exist {
+		regionIndex[key] = code
+	}
+}
+
+// Split the dataset names like "Madrid, Comunidad de", "Wales; Cymru", "Abū Ȥaby [Abu Dhabi]" and
+// "Navarra / Nafarroa" into their parts.
+func alternativeNames(name string) []string {
+	name = strings.NewReplacer("[", ";", "]", "", " / ", ";", ",", ";").Replace(name)
+
+	var names []string
+	for part := range strings.SplitSeq(name, ";") {
+		part = strings.TrimSpace(part)
+		if len(part) > 0 {
+			names = append(names, part)
+		}
+	}
+	return names
+}
+
+// Lowercase, remove diacritics, remarks in parentheses and any non-alphanumeric characters.
+// e.g. "Provence-Alpes-Côte-d’Azur" -> "provencealpescotedazur"
+func normalizeRegionName(name string, stripType bool) string {
+	name, _, _ = strings.Cut(name, "(")
+	name = strings.NewReplacer("đ", "d", "Đ", "D", "ł", "l", "Ł", "L", "ø", "o", "Ø", "O", "ß", "ss", "ı", "i").Replace(name)
+
+	var words []string
+	var word strings.Builder
+	for _, r := range norm.NFD.String(name) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// drop diacritics
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word.WriteRune(unicode.ToLower(r))
+		case r == '\'' || r == '’' || r == '`':
+			// "Gang'weondo" -> "gangweondo"
+		default:
+			if word.Len() > 0 {
+				words = append(words, word.String())
+				word.Reset()
+			}
+		}
+	}
+	if word.Len() > 0 {
+		words = append(words, word.String())
+	}
+
+	if stripType {
+		stripped := slices.DeleteFunc(slices.Clone(words), func(w string) bool {
+			return slices.Contains(regionTypeWords, w)
+		})
+		// keep the name that only has type words, like "City"
+		if len(stripped) > 0 {
+			words = stripped
+		}
+	}
+
+	return strings.Join(words, "")
+}
@@ -0,0 +1,134 @@
+package geo
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/biter777/countries"
+
+	"github.com/SourLemonJuice/ipapi-agent/response"
+)
+
+func TestNormalizeRegionName(t *testing.T) {
+	tests := []struct {
+		name      string
+		stripType bool
+		want      string
+	}{
+		{"Provence-Alpes-Côte-d’Azur", false, "provencealpescotedazur"},
+		{"Île-de-France", false, "iledefrance"},
+		{"Gang'weondo", false, "gangweondo"},
+		{"Łódzkie", false, "lodzkie"},
+		{"Thüringen", false, "thuringen"},
+		{"Schleswig-Holstein", false, "schleswigholstein"},
+		{"Møre og Romsdal", false, "moreogromsdal"},
+		{"Đà Nẵng", false, "danang"},
+		{"İstanbul", false, "istanbul"},
+		{"Bayern (Bavaria)", false, "bayern"},
+		{"Tokyo Prefecture", false, "tokyoprefecture"},
+		// type words are removed
+		{"Tokyo Prefecture", true, "tokyo"},
+		{"Beijing Shi", true, "beijing"},
+		{"State of São Paulo", true, "ofsaopaulo"},
+		{"Capital Region", true, "capital"},
+		// the name only has type words is kept
+		{"City", true, "city"},
+		{"", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := normalizeRegionName(tt.name, tt.stripType)
+			if got != tt.want {
+				t.Errorf("normalizeRegionName(%q, %v) = %q, want %q", tt.name, tt.stripType, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlternativeNames(t *testing.T) {
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"Bayern", []string{"Bayern"}},
+		{"Madrid, Comunidad de", []string{"Madrid", "Comunidad de"}},
+		{"Wales; Cymru", []string{"Wales", "Cymru"}},
+		{"Abū Ȥaby [Abu Dhabi]", []string{"Abū Ȥaby", "Abu Dhabi"}},
+		{"Navarra / Nafarroa", []string{"Navarra", "Nafarroa"}},
+		{"Bruxelles-Capitale, Région de;Brussels Hoofdstedelijk Gewest", []string{"Bruxelles-Capitale", "Région de", "Brussels Hoofdstedelijk Gewest"}},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := alternativeNames(tt.name)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("alternativeNames(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegionAlias(t *testing.T) {
+	for code, names := range regionAlias {
+		if !countries.SubdivisionCode(code).IsValid() {
+			t.Errorf("regionAlias has an unknown code %v", code)
+			continue
+		}
+
+		country, _, _ := strings.Cut(code, "-")
+		for _, name := range names {
+			got := regionCodeByName(country, name)
+			if got != code {
+				t.Errorf("alias %q of %v is resolved to %q", name, code, got)
+			}
+		}
+	}
+}
+
+func TestFillRegion(t *testing.T) {
+	tests := []struct {
+		countryCode string
+		regionCode  string
+		region      string
+		want        string
+	}{
+		// the valid code of upstream is kept
+		{"DE", "de-by", "", "DE-BY"},
+		{"DE", "DE-BY", "Hesse", "DE-BY"},
+		// not usable codes, found by name
+		{"DE", "BY", "Bavaria", "DE-BY"},
+		{"DE", "FR-IDF", "Bayern", "DE-BY"},
+		// names of the dataset and the aliases
+		{"DE", "", "Nordrhein-Westfalen", "DE-NW"},
+		{"DE", "", "North Rhine-Westphalia", "DE-NW"},
+		{"ES", "", "Madrid", "ES-MD"},
+		{"ES", "", "Madrid, Comunidad de", "ES-MD"},
+		// the exact name wins over the one without type words
+		{"RU", "", "Moscow", "RU-MOW"},
+		{"RU", "", "Moscow Oblast", "RU-MOS"},
+		{"ES", "", "Navarre", "ES-NC"},
+		{"CN", "", "Beijing", "CN-BJ"},
+		{"CN", "", "Tibet", "CN-XZ"},
+		{"JP", "", "Tokyo", "JP-13"},
+		{"FR", "", "Île-de-France", "FR-IDF"},
+		{"FR", "", "ILE DE FRANCE", "FR-IDF"},
+		{"AE", "", "Abu Dhabi", "AE-AZ"},
+		// nothing found
+		{"DE", "", "Atlantis", ""},
+		{"DE", "", "", ""},
+		{"", "", "Bavaria", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.countryCode+" "+tt.regionCode+" "+tt.region, func(t *testing.T) {
+			resp := response.Query{CountryCode: tt.countryCode, RegionCode: tt.regionCode, Region: tt.region}
+			FillRegion(&resp)
+			if resp.RegionCode != tt.want {
+				t.Errorf("FillRegion(%q, %q, %q) = %q, want %q", tt.countryCode, tt.regionCode, tt.region, resp.RegionCode, tt.want)
+			}
+		})
+	}
+}
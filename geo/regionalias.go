@@ -0,0 +1,170 @@
+package geo
+
+// English names used by the upstreams, which are different from the local names in dataset.
+// Key is the ISO 3166-2 code.
+var regionAlias = map[string][]string{
+	// Austria
+	"AT-2": {"Carinthia"},
+	"AT-3": {"Lower Austria"},
+	"AT-4": {"Upper Austria"},
+	"AT-6": {"Styria"},
+	"AT-7": {"Tyrol"},
+	"AT-9": {"Vienna"},
+	// Belgium
+	"BE-BRU": {"Brussels Capital", "Brussels"},
+	"BE-VLG": {"Flanders"},
+	"BE-WAL": {"Wallonia"},
+	// Switzerland
+	"CH-GE": {"Geneva"},
+	"CH-LU": {"Lucerne"},
+	"CH-SG": {"St.-Gallen", "Saint Gallen"},
+	// China
+	"CN-GX": {"Guangxi"},
+	"CN-NM": {"Inner Mongolia"},
+	"CN-NX": {"Ningxia Hui Autonomous Region"},
+	"CN-XJ": {"Xinjiang"},
+	"CN-XZ": {"Tibet"},
+	// Czechia
+	"CZ-10": {"Prague"},
+	"CZ-20": {"Central Bohemia"},
+	"CZ-64": {"South Moravian"},
+	// Germany
+	"DE-BY": {"Bavaria"},
+	"DE-HE": {"Hesse"},
+	"DE-NI": {"Lower Saxony"},
+	"DE-NW": {"North Rhine-Westphalia"},
+	"DE-RP": {"Rhineland-Palatinate"},
+	"DE-SN": {"Saxony"},
+	"DE-ST": {"Saxony-Anhalt"},
+	"DE-TH": {"Thuringia"},
+	// Denmark
+	"DK-81": {"North Denmark"},
+	"DK-82": {"Central Jutland"},
+	"DK-83": {"South Denmark"},
+	"DK-84": {"Capital Region"},
+	"DK-85": {"Zealand"},
+	// Egypt
+	"EG-ALX": {"Alexandria"},
+	"EG-C":   {"Cairo"},
+	"EG-GZ":  {"Giza"},
+	// Spain
+	"ES-AN": {"Andalusia"},
+	"ES-AR": {"Aragon"},
+	"ES-CL": {"Castille and León", "Castile and León"},
+	"ES-CM": {"Castille-La Mancha", "Castile-La Mancha"},
+	"ES-CN": {"Canary Islands"},
+	"ES-CT": {"Catalonia"},
+	"ES-IB": {"Balearic Islands"},
+	"ES-MC": {"Murcia"},
+	"ES-MD": {"Madrid"},
+	"ES-NC": {"Navarre"},
+	"ES-PV": {"Basque Country"},
+	"ES-VC": {"Valencia"},
+	// France
+	"FR-BFC": {"Burgundy-Franche-Comté"},
+	"FR-BRE": {"Brittany"},
+	"FR-COR": {"Corsica"},
+	"FR-GES": {"Grand Est"},
+	"FR-NOR": {"Normandy"},
+	"FR-OCC": {"Occitania"},
+	"FR-PAC": {"Provence-Alpes-Côte d'Azur"},
+	// Greece
+	"GR-A": {"East Macedonia and Thrace"},
+	"GR-B": {"Central Macedonia"},
+	"GR-I": {"Attica"},
+	"GR-M": {"Crete"},
+	// Indonesia
+	"ID-JB": {"West Java"},
+	"ID-JI": {"East Java"},
+	"ID-JK": {"Jakarta"},
+	"ID-JT": {"Central Java"},
+	// Israel
+	"IL-D":  {"Southern District"},
+	"IL-HA": {"Haifa"},
+	"IL-JM": {"Jerusalem"},
+	"IL-M":  {"Central District"},
+	"IL-TA": {"Tel Aviv"},
+	"IL-Z":  {"Northern District"},
+	// India
+	"IN-DL": {"National Capital Territory of Delhi"},
+	// Italy
+	"IT-21": {"Piedmont"},
+	"IT-23": {"Aosta Valley"},
+	"IT-25": {"Lombardy"},
+	"IT-32": {"Trentino-Alto Adige/Südtirol"},
+	"IT-52": {"Tuscany"},
+	"IT-75": {"Apulia"},
+	"IT-82": {"Sicily"},
+	"IT-88": {"Sardinia"},
+	// South Korea
+	"KR-11": {"Seoul"},
+	"KR-26": {"Busan"},
+	"KR-27": {"Daegu"},
+	"KR-28": {"Incheon"},
+	"KR-29": {"Gwangju"},
+	"KR-30": {"Daejeon"},
+	"KR-31": {"Ulsan"},
+	"KR-42": {"Gangwon-do"},
+	"KR-45": {"Jeollabuk-do"},
+	"KR-46": {"Jeollanam-do"},
+	// Malaysia
+	"MY-07": {"Penang"},
+	"MY-14": {"Kuala Lumpur"},
+	"MY-15": {"Labuan"},
+	"MY-16": {"Putrajaya"},
+	// Mexico
+	"MX-CMX": {"Mexico City"},
+	// Netherlands
+	"NL-NB": {"North Brabant"},
+	"NL-NH": {"North Holland"},
+	"NL-ZH": {"South Holland"},
+	// Philippines
+	"PH-00": {"Metro Manila"},
+	// Poland
+	"PL-DS": {"Lower Silesia"},
+	"PL-KP": {"Kujawsko-Pomorskie", "Kuyavian-Pomerania"},
+	"PL-LD": {"Łódź Voivodeship"},
+	"PL-LU": {"Lublin"},
+	"PL-MA": {"Lesser Poland"},
+	"PL-MZ": {"Mazovia"},
+	"PL-PD": {"Podlasie"},
+	"PL-PK": {"Subcarpathia"},
+	"PL-PM": {"Pomerania"},
+	"PL-SK": {"Świętokrzyskie"},
+	"PL-SL": {"Silesia"},
+	"PL-WN": {"Warmia-Masuria"},
+	"PL-WP": {"Greater Poland"},
+	"PL-ZP": {"West Pomerania"},
+	// Portugal
+	"PT-11": {"Lisbon"},
+	"PT-20": {"Azores"},
+	"PT-30": {"Madeira"},
+	// Romania
+	"RO-B": {"Bucharest"},
+	// Russia
+	"RU-LEN": {"Leningrad Oblast"},
+	"RU-MOS": {"Moscow Oblast"},
+	"RU-MOW": {"Moscow"},
+	"RU-SPE": {"St.-Petersburg", "Saint Petersburg"},
+	"RU-TA":  {"Tatarstan Republic"},
+	// Saudi Arabia
+	"SA-01": {"Riyadh Region"},
+	"SA-02": {"Mecca Region"},
+	"SA-03": {"Medina Region"},
+	"SA-04": {"Eastern Province"},
+	// Sweden
+	"SE-AB": {"Stockholm"},
+	"SE-M":  {"Skåne"},
+	"SE-O":  {"Västra Götaland"},
+	// Thailand
+	"TH-10": {"Bangkok"},
+	// Ukraine
+	"UA-30": {"Kyiv City"},
+	"UA-32": {"Kyiv Oblast"},
+	// United Arab Emirates
+	"AE-AZ": {"Abu Dhabi"},
+	"AE-DU": {"Dubai"},
+	"AE-SH": {"Sharjah"},
+	// Vietnam
+	"VN-SG": {"Ho Chi Minh"},
+}
@@ -0,0 +1,25 @@
+package geo
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/SourLemonJuice/ipapi-agent/response"
+)
+
+// Fill the time related fields of the Query at the given moment, based on its Timezone.
+// Those fields are changing over time(e.g. DST), so don't cache them, fill them when responding.
+func FillTime(resp *response.Query, at time.Time) error {
+	tz, err := time.LoadLocation(resp.Timezone)
+	if err != nil {
+		return fmt.Errorf("can not load timezone: %w", err)
+	}
+
+	local := at.In(tz)
+	_, offsetSec := local.Zone()
+	resp.UTCOffset = offsetSec / 60
+	resp.IsDST = local.IsDST()
+	resp.LocalTime = local.Format(time.RFC3339)
+
+	return nil
+}
@@ -9,29 +9,44 @@ import (
 	"net"
 	"net/http"
 	"net/netip"
+	"net/url"
 	"os"
+	"os/signal"
+	"path"
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"text/tabwriter"
 	"time"
 
 	"github.com/fatih/color"
 	"github.com/gin-gonic/gin"
-	"github.com/patrickmn/go-cache"
+	"golang.org/x/net/idna"
 	"golang.org/x/net/publicsuffix"
 
 	"github.com/SourLemonJuice/ipapi-agent/build"
+	"github.com/SourLemonJuice/ipapi-agent/cache"
 	"github.com/SourLemonJuice/ipapi-agent/config"
 	C "github.com/SourLemonJuice/ipapi-agent/constant"
 	"github.com/SourLemonJuice/ipapi-agent/debug"
+	"github.com/SourLemonJuice/ipapi-agent/domainrule"
+	"github.com/SourLemonJuice/ipapi-agent/geo"
+	"github.com/SourLemonJuice/ipapi-agent/override"
+	"github.com/SourLemonJuice/ipapi-agent/privacy"
+	"github.com/SourLemonJuice/ipapi-agent/rdns"
+	"github.com/SourLemonJuice/ipapi-agent/resolver"
 	"github.com/SourLemonJuice/ipapi-agent/response"
+	"github.com/SourLemonJuice/ipapi-agent/special"
 	"github.com/SourLemonJuice/ipapi-agent/upstream"
 )
 
 var (
 	conf       config.Config
-	queryCache *cache.Cache = cache.New(6*time.Hour, 30*time.Minute)
+	queryCache cache.Backend[response.Query]
+	// resolver of domain queries
+	domainResolver resolver.Resolver = resolver.New(config.DefaultResolver)
 )
 
 func init() {
@@ -45,11 +60,12 @@ func init() {
 func main() {
 	flag.BoolFunc("version", "print version information of ipapi-agent", flagVersion)
 	confPath := flag.String("config", "", "set config file path")
+	testDomain := flag.String("test-domain", "", "print which domain rule matches the domain, then exit")
 	flag.Parse()
 
 	log.Print("initializing...")
 
-	err := findConfig(&conf, *confPath)
+	path, err := findConfig(&conf, *confPath)
 	if err != nil {
 		log.Println(err)
 		os.Exit(1)
@@ -63,16 +79,61 @@ func main() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	err = privacy.Load(conf.Privacy)
+	if err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+
+	override.Load(conf.Override)
+	go reloadOnSignal(path)
+
+	err = domainrule.Load(conf.Domain)
+	if err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+	if len(*testDomain) > 0 {
+		testDomainRule(*testDomain)
+		os.Exit(0)
+	}
+
+	err = special.Load(conf.Special)
+	if err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+
+	err = loadTextTemplates(conf.Text)
+	if err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+
+	domainResolver = resolver.New(conf.Domain.Resolver)
+	queryCache, err = cache.New[response.Query](conf.Cache)
+	if err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+	if conf.Cache.Enabled && len(conf.Cache.DiskPath) > 0 {
+		go flushOnExit()
+	}
+
 	upstream.InitSelector(conf.Upstream)
 
 	router := gin.New()
 	router.RedirectTrailingSlash = true
 	router.RemoveExtraSlash = true
+	// route with the escaped path, so a URL like "/https:%2F%2Fexample.com%2F" stays in one path parameter
+	router.UseRawPath = true
 	router.Use(gin.Recovery())
 	if conf.Dev.Log {
 		router.Use(gin.Logger())
 	}
 
+	router.SetHTMLTemplate(htmlTemplate)
+
 	err = router.SetTrustedProxies(conf.TrustedProxies)
 	if err != nil {
 		log.Printf("can't set trusted proxies: %v", err)
@@ -84,6 +145,13 @@ func main() {
 	router.GET("/query", getQuery)
 	router.GET("/query/:addr", getQuery)
 
+	for _, field := range []string{"ip", "country", "countryCode", "region", "asn", "org", "timezone", formatLine, formatShell} {
+		router.GET("/"+field, getField)
+	}
+	router.GET("/:addr/:field", getField)
+	router.GET("/query/:addr/:field", getField)
+	router.GET("/domain/:name", getDomain)
+
 	router.GET("/generate_204", func(c *gin.Context) {
 		c.Status(http.StatusNoContent)
 	})
@@ -103,7 +171,36 @@ func flagVersion(s string) error {
 	return nil
 }
 
-func findConfig(conf *config.Config, hint string) error {
+// Print the result of domain rules for the -test-domain flag.
+func testDomainRule(input string) {
+	domain, err := normalizeQuery(input)
+	if err != nil {
+		fmt.Printf("bad domain: %v\n", err)
+		return
+	}
+	fmt.Printf("domain: %v\n", domain)
+
+	suffix, _ := publicsuffix.PublicSuffix(domain)
+	if slices.Contains(conf.Domain.BlockSuffix, suffix) {
+		fmt.Printf("blocked by suffix: %v\n", suffix)
+		return
+	}
+
+	allowed, rule := domainrule.Check(domain)
+	if rule != nil {
+		fmt.Printf("matched rule: %v\n", rule)
+	} else {
+		fmt.Printf("no rule matched, mode: %v\n", domainrule.Mode())
+	}
+	if allowed {
+		fmt.Println("result: allowed")
+	} else {
+		fmt.Println("result: denied")
+	}
+}
+
+// Return the path of the loaded config file, empty if no file.
+func findConfig(conf *config.Config, hint string) (string, error) {
 	*conf = config.Default()
 
 	var path string
@@ -123,108 +220,494 @@ func findConfig(conf *config.Config, hint string) error {
 		log.Printf("loading config file %v", path)
 		err := conf.DecodeFile(path)
 		if err != nil {
-			return fmt.Errorf("can't load config file: %w", err)
+			return "", fmt.Errorf("can't load config file: %w", err)
 		}
 	} else {
 		log.Print("no config file provided, use defaults")
 	}
 
-	return nil
+	return path, nil
+}
+
+// Reload the config file when SIGHUP is received, only the [[override]] tables are applied.
+// Other sections still need a restart.
+func reloadOnSignal(path string) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+
+	for range sig {
+		if len(path) == 0 {
+			log.Print("no config file to reload")
+			continue
+		}
+
+		newConf := config.Default()
+		err := newConf.DecodeFile(path)
+		if err != nil {
+			// keep the old one
+			log.Printf("can't reload config file: %v", err)
+			continue
+		}
+
+		override.Load(newConf.Override)
+		log.Printf("config file %v reloaded, overrides: %v", path, len(newConf.Override))
+	}
 }
 
+// Write the queued disk cache entries before exiting, the server itself has no graceful shutdown.
+func flushOnExit() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+
+	s := <-sig
+	log.Printf("received %v, closing the disk cache", s)
+	err := queryCache.Close()
+	if err != nil {
+		log.Printf("can't close the disk cache: %v", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// The human interface, plain text by default.
 func getRoot(c *gin.Context) {
-	// Use \r\n (CRLF) as line break symbol in this function, which it is Windows and HTTP format.
-	// Also, don't forget the last line break at the body end.
+	// from the search box of HTML page
+	if q := c.Query("q"); q != "" && c.Param("addr") == "" {
+		c.Redirect(http.StatusFound, "/"+url.PathEscape(q))
+		return
+	}
 
-	ctx, cancel := context.WithTimeout(c.Request.Context(), conf.Dev.UpstreamTimeout)
-	defer cancel()
+	out, err := newOutput(c, formatText)
+	if err != nil {
+		out.failure(c, http.StatusBadRequest, "Bad output option")
+		return
+	}
+
+	all, err := wantAll(c)
+	if err != nil {
+		out.failure(c, http.StatusBadRequest, "Bad all option")
+		return
+	}
+	if all {
+		handleDomain(c, out, c.Param("addr"))
+		return
+	}
+
+	handleQuery(c, out)
+}
+
+// The REST API, JSON by default.
+func getQuery(c *gin.Context) {
+	out, err := newOutput(c, formatJSON)
+	if err != nil {
+		out.failure(c, http.StatusBadRequest, "Bad output option")
+		return
+	}
 
-	colorful := false
-	if strings.HasPrefix(c.GetHeader("User-Agent"), "curl") {
-		colorful = true
+	all, err := wantAll(c)
+	if err != nil {
+		out.failure(c, http.StatusBadRequest, "Bad all option")
+		return
 	}
+	if all {
+		handleDomain(c, out, c.Param("addr"))
+		return
+	}
+
+	handleQuery(c, out)
+}
+
+// All the resolved addresses of a domain, JSON by default.
+func getDomain(c *gin.Context) {
+	out, err := newOutput(c, formatJSON)
+	if err != nil {
+		out.failure(c, http.StatusBadRequest, "Bad output option")
+		return
+	}
+
+	handleDomain(c, out, c.Param("name"))
+}
+
+// The "all" query string, only works with a domain in path, it's ignored for an IP address.
+func wantAll(c *gin.Context) (bool, error) {
+	all, err := strconv.ParseBool(c.DefaultQuery("all", "false"))
+	if err != nil {
+		return false, err
+	}
+	if !all || c.Param("addr") == "" {
+		return false, nil
+	}
+
+	// also the IP address forms like "[2001:db8::1]:8080", a bad input is left to the domain query
+	query, err := normalizeQuery(c.Param("addr"))
+	if err == nil {
+		if _, err := netip.ParseAddr(query); err == nil {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Single-field endpoints for shell scripts, like "/countryCode" or "/1.1.1.1/countryCode".
+// Also the compact formats, like "/line" or "/1.1.1.1/shell".
+func getField(c *gin.Context) {
+	field := c.Param("field")
+	if field == "" {
+		// the routes without address, like "/countryCode"
+		field = path.Base(c.FullPath())
+	}
+
+	// the format is decided by path, but the ascii and color options still work
+	out, err := newOutput(c, formatField)
+	out.format = formatField
+	if err != nil {
+		out.failure(c, http.StatusBadRequest, "Bad output format")
+		return
+	}
+
+	// the path suffix of formats, like "/1.1.1.1/shell"
+	if field == formatLine || field == formatShell {
+		out.format = field
+		handleQuery(c, out)
+		return
+	}
+
+	if _, ok := queryFields[field]; !ok {
+		out.failure(c, http.StatusNotFound, "Unknown field")
+		return
+	}
+
+	out.field = field
+	handleQuery(c, out)
+}
+
+// Query the IP address/domain from path or the client IP address, then respond with out.
+func handleQuery(c *gin.Context, out output) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), conf.Dev.UpstreamTimeout)
+	defer cancel()
 
 	query := c.Param("addr")
 	if query == "" {
 		query = c.ClientIP()
 	}
 
-	addrStr, err := parseQuery(query)
+	family, err := parseFamily(c)
+	if err != nil {
+		out.failure(c, http.StatusBadRequest, "Bad family option, should be 4 or 6")
+		return
+	}
+
+	useReserved, err := strconv.ParseBool(c.DefaultQuery("reserved", strconv.FormatBool(conf.Special.ReservedResponse)))
+	if err != nil {
+		out.failure(c, http.StatusBadRequest, "Bad reserved option")
+		return
+	}
+
+	useCache, err := strconv.ParseBool(c.DefaultQuery("cache", "true"))
+	if err != nil {
+		out.failure(c, http.StatusBadRequest, "Bad cache option")
+		return
+	}
+
+	at, err := parseAt(c)
+	if err != nil {
+		out.failure(c, http.StatusBadRequest, "Bad time format, should be RFC 3339")
+		return
+	}
+
+	query, err = normalizeQuery(query)
 	if err != nil {
 		log.Printf("Bad IP address/domain: %v", err)
-		c.Abort()
-		c.String(http.StatusBadRequest, respTXTFailure(colorful, "Bad IP address/domain"))
+		var nameErr *domainNameError
+		if errors.As(err, &nameErr) {
+			out.failure(c, http.StatusBadRequest, "Bad domain name, "+nameErr.Reason)
+			return
+		}
+		out.failure(c, http.StatusBadRequest, "Bad IP address/domain")
 		return
 	}
+	queryUnicode, queryASCII := queryForms(query)
 
-	var resp response.Query
-	if val, found := queryCache.Get(addrStr); found {
-		resp = val.(response.Query)
-		c.String(http.StatusOK, respTXT(colorful, addrStr, resp))
+	addrStr, err := parseQuery(ctx, query, family)
+	var rejectErr *special.RejectError
+	rejected := errors.As(err, &rejectErr)
+	if err != nil && !rejected {
+		log.Printf("Bad IP address/domain: %v", err)
+		if errors.Is(err, errDomainDenied) {
+			out.failure(c, http.StatusForbidden, "Domain is not allowed")
+			return
+		}
+		out.failure(c, http.StatusBadRequest, "Bad IP address/domain")
 		return
 	}
 
-	api, err := upstream.SelectAPI(conf.Upstream)
+	// overrides go before the special-purpose filter and the cache
+	resp, overridden := overrideQuery(netip.MustParseAddr(addrStr))
+	if rejected && !overridden {
+		if useReserved {
+			resp = reservedQuery(netip.MustParseAddr(addrStr), rejectErr.Entry)
+			resp.Query, resp.QueryASCII = queryUnicode, queryASCII
+			out.query(c, addrStr, resp)
+			return
+		}
+
+		log.Printf("Bad IP address/domain: %v", err)
+		out.failure(c, http.StatusBadRequest, "Special-purpose address: "+rejectErr.Entry.Name)
+		return
+	}
+
+	// no need to lookup if only the address is wanted
+	if out.field == "ip" {
+		out.query(c, addrStr, response.Query{Query: queryUnicode, QueryASCII: queryASCII})
+		return
+	}
+
+	if !overridden {
+		resp, err = cachedQuery(ctx, addrStr, useCache)
+		if err != nil {
+			log.Printf("Upstream error: %v", err)
+			out.failure(c, http.StatusInternalServerError, "Upstream error")
+			return
+		}
+	}
+
+	err = geo.FillTime(&resp, at)
 	if err != nil {
-		log.Fatalf("Can't select API: %v", err)
-		c.Abort()
-		c.String(http.StatusInternalServerError, respTXTFailure(colorful, "Internal Server Error"))
+		log.Printf("Can't fill time fields: %v", err)
+		out.failure(c, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
 
-	resp, err = api.Fetch(ctx, addrStr)
+	// not cached, different queries may have the same address
+	resp.Query, resp.QueryASCII = queryUnicode, queryASCII
+	out.query(c, addrStr, resp)
+}
+
+// The Query of the [[override]] in config that matches addr.
+func overrideQuery(addr netip.Addr) (response.Query, bool) {
+	resp, ok := override.Lookup(addr)
+	if !ok {
+		return resp, false
+	}
+
+	resp.Family = addrFamily(addr)
+	return resp, true
+}
+
+// The success-shaped response of a special-purpose address, without upstream call.
+func reservedQuery(addr netip.Addr, entry special.Entry) response.Query {
+	return response.Query{
+		Status:     C.ResponseStatusSuccess,
+		DataSource: "iana",
+		Family:     addrFamily(addr),
+		Reserved: &response.Reserved{
+			Name:        entry.Name,
+			Description: entry.Description,
+			Prefix:      entry.Prefix.String(),
+			RFC:         entry.RFC,
+		},
+	}
+}
+
+// Query every resolved address of the domain concurrently, then respond with out.
+func handleDomain(c *gin.Context, out output, name string) {
+	if !slices.Contains([]string{formatText, formatJSON, formatYAML, formatXML, formatHTML}, out.format) {
+		out.failure(c, http.StatusBadRequest, "Output format not supported by domain query")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), conf.Dev.UpstreamTimeout)
+	defer cancel()
+
+	if !conf.Domain.Enabled {
+		log.Print("Bad IP address/domain: not permitted to resolve domain")
+		out.failure(c, http.StatusBadRequest, "Bad IP address/domain")
+		return
+	}
+
+	family, err := parseFamily(c)
 	if err != nil {
-		log.Printf("Upstream error: %v", err)
-		c.Abort()
-		c.String(http.StatusInternalServerError, respTXTFailure(colorful, "Upstream error"))
+		out.failure(c, http.StatusBadRequest, "Bad family option, should be 4 or 6")
 		return
 	}
 
-	// let struct cache compatible with getQuery()
-	resp.Status = C.ResponseStatusSuccess
+	name, err = normalizeQuery(name)
+	if err != nil {
+		log.Printf("Bad IP address/domain: %v", err)
+		var nameErr *domainNameError
+		if errors.As(err, &nameErr) {
+			out.failure(c, http.StatusBadRequest, "Bad domain name, "+nameErr.Reason)
+			return
+		}
+		out.failure(c, http.StatusBadRequest, "Bad IP address/domain")
+		return
+	}
+	// an IP address is never sent to the resolver, query it with /query/<IP addr> instead
+	if _, err := netip.ParseAddr(name); err == nil {
+		out.failure(c, http.StatusBadRequest, "Bad domain name, it's an IP address")
+		return
+	}
+
+	addrStrArr, err := lookupDomain(ctx, name, family, false)
+	if err != nil {
+		log.Printf("Bad IP address/domain: %v", err)
+		if errors.Is(err, errDomainDenied) {
+			out.failure(c, http.StatusForbidden, "Domain is not allowed")
+			return
+		}
+		out.failure(c, http.StatusBadRequest, "Bad IP address/domain")
+		return
+	}
 
-	queryCache.SetDefault(addrStr, resp)
+	useCache, err := strconv.ParseBool(c.DefaultQuery("cache", "true"))
+	if err != nil {
+		out.failure(c, http.StatusBadRequest, "Bad cache option")
+		return
+	}
 
-	c.String(http.StatusOK, respTXT(colorful, addrStr, resp))
+	at, err := parseAt(c)
+	if err != nil {
+		out.failure(c, http.StatusBadRequest, "Bad time format, should be RFC 3339")
+		return
+	}
+
+	unicode, ascii := queryForms(name)
+	resp := response.Domain{
+		Status:      C.ResponseStatusSuccess,
+		Domain:      unicode,
+		DomainASCII: ascii,
+		Addresses:   make([]response.DomainAddr, len(addrStrArr)),
+	}
+
+	// limit the concurrent upstream requests
+	sem := make(chan struct{}, conf.Domain.LookupConcurrency)
+	var wg sync.WaitGroup
+	for i, addrStr := range addrStrArr {
+		wg.Go(func() {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			resp.Addresses[i] = queryDomainAddr(ctx, addrStr, useCache, at)
+		})
+	}
+	wg.Wait()
+
+	out.domain(c, resp)
 }
 
-func respTXTFailure(colorful bool, format string, obj ...any) string {
+// Query one of the resolved addresses, the failure is recorded in the result instead of the whole response.
+func queryDomainAddr(ctx context.Context, addrStr string, useCache bool, at time.Time) response.DomainAddr {
+	result := response.DomainAddr{
+		Addr: addrStr,
+	}
+
+	addr, err := netip.ParseAddr(addrStr)
+	if err != nil {
+		result.Status = C.ResponseStatusFailure
+		result.Message = "Bad IP address"
+		return result
+	}
+
+	result.Family = addrFamily(addr)
+
+	resp, overridden := overrideQuery(addr)
+	if !overridden {
+		var rejectErr *special.RejectError
+		if errors.As(special.Check(addr), &rejectErr) {
+			result.Status = C.ResponseStatusRejected
+			result.Message = rejectErr.Entry.Name
+			return result
+		}
+
+		resp, err = cachedQuery(ctx, addrStr, useCache)
+		if err != nil {
+			log.Printf("Upstream error: %v", err)
+			result.Status = C.ResponseStatusFailure
+			result.Message = "Upstream error"
+			return result
+		}
+	}
+
+	err = geo.FillTime(&resp, at)
+	if err != nil {
+		log.Printf("Can't fill time fields: %v", err)
+		result.Status = C.ResponseStatusFailure
+		result.Message = "Internal Server Error"
+		return result
+	}
+
+	result.Status = C.ResponseStatusSuccess
+	result.Query = &resp
+	return result
+}
+
+// Use \r\n (CRLF) as line break symbol in the text output, which it is Windows and HTTP format.
+// Also, don't forget the last line break at the body end.
+func respTXTFailure(out output, format string, obj ...any) string {
 	var txt strings.Builder
 	cRed := color.New(color.FgHiRed)
-	if !colorful {
+	if !out.colorful {
 		cRed.DisableColor()
 	}
 
 	// U+00D7 Multiplication Sign: ×
-	txt.WriteString(cRed.Sprint("\u00d7 FAILURE"))
-	txt.WriteString("\r\n")
+	cross := "\u00d7"
+	if out.ascii {
+		cross = "x"
+	}
+	// the field output is for shell scripts, LF like its success output
+	newline := "\r\n"
+	if out.format == formatField {
+		newline = "\n"
+	}
+
+	txt.WriteString(cRed.Sprint(cross + " FAILURE"))
+	txt.WriteString(newline)
 	txt.WriteString(fmt.Sprintf(format, obj...))
-	txt.WriteString("\r\n")
+	txt.WriteString(newline)
 
 	return txt.String()
 }
 
-func respTXT(colorful bool, addrStr string, resp response.Query) string {
+func respTXT(out output, addrStr string, resp response.Query) string {
 	var txt strings.Builder
 	cGreen := color.New(color.FgHiGreen)
 	cYellow := color.New(color.FgHiYellow, color.Bold)
-	if !colorful {
+	if !out.colorful {
 		cGreen.DisableColor()
 		cYellow.DisableColor()
 	}
 
 	// U+25CF Black Circle: ●
 	// from systemctl status ^_^
-	txt.WriteString(cGreen.Sprint("\u25cf"))
+	dot := "\u25cf"
+	if out.ascii {
+		dot = "*"
+	}
+	txt.WriteString(cGreen.Sprint(dot))
 	txt.WriteString(fmt.Sprintf(" %v", addrStr))
+	if len(resp.Hostname) > 0 {
+		txt.WriteString(fmt.Sprintf(" (%v)", resp.Hostname))
+	}
 	if resp.Anycast {
 		txt.WriteString(cYellow.Sprint(" (Anycast)"))
 	}
 	txt.WriteString(fmt.Sprintf(" - %v\r\n", resp.DataSource))
 
 	tab := tabwriter.NewWriter(&txt, 2, 0, 0, ' ', tabwriter.AlignRight)
+	if resp.Reserved != nil {
+		fmt.Fprintf(tab, "\tReserved: \t%v\r\n", cYellow.Sprint(resp.Reserved.Description))
+		fmt.Fprintf(tab, "\tBlock: \t%v (%v)\r\n", resp.Reserved.Prefix, resp.Reserved.Name)
+		fmt.Fprintf(tab, "\tRFC: \t%v\r\n", strings.Join(resp.Reserved.RFC, ", "))
+		tab.Flush()
+		return txt.String()
+	}
+
 	fmt.Fprintf(tab, "\tLocation: \t%v, %v (%v)\r\n", resp.Region, resp.Country, resp.CountryCode)
-	fmt.Fprintf(tab, "\tTimezone: \t%v %v\r\n", resp.Timezone, utcOffsetToISO8601(resp.UTCOffset))
+	if resp.IsDST {
+		fmt.Fprintf(tab, "\tTimezone: \t%v %v (DST)\r\n", resp.Timezone, utcOffsetToISO8601(resp.UTCOffset))
+	} else {
+		fmt.Fprintf(tab, "\tTimezone: \t%v %v\r\n", resp.Timezone, utcOffsetToISO8601(resp.UTCOffset))
+	}
 
 	if len(resp.Org) == 0 {
 		fmt.Fprintf(tab, "\tOrg: \t<Unavailable>\r\n")
@@ -236,71 +719,265 @@ func respTXT(colorful bool, addrStr string, resp response.Query) string {
 	}
 
 	fmt.Fprintf(tab, "\tASN: \t%v\r\n", resp.ASN)
+
+	if resp.Privacy.Any() {
+		fmt.Fprintf(tab, "\tFlags: \t%v\r\n", cYellow.Sprint(privacyFlags(resp.Privacy)))
+	}
 	tab.Flush()
 
 	return txt.String()
 }
 
-func getQuery(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(c.Request.Context(), conf.Dev.UpstreamTimeout)
-	defer cancel()
-
-	query := c.Param("addr")
-	if query == "" {
-		query = c.ClientIP()
+func respTXTDomain(out output, resp response.Domain) string {
+	var txt strings.Builder
+	cGreen := color.New(color.FgHiGreen)
+	cRed := color.New(color.FgHiRed)
+	if !out.colorful {
+		cGreen.DisableColor()
+		cRed.DisableColor()
 	}
 
-	addrStr, err := parseQuery(query)
-	if err != nil {
-		log.Printf("Bad IP address/domain: %v", err)
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"status":  C.ResponseStatusFailure,
-			"message": "Bad IP address/domain",
-		})
-		return
+	dot := "\u25cf"
+	if out.ascii {
+		dot = "*"
 	}
+	txt.WriteString(cGreen.Sprint(dot))
+	txt.WriteString(fmt.Sprintf(" %v - %v addresses\r\n", resp.Domain, len(resp.Addresses)))
+
+	tab := tabwriter.NewWriter(&txt, 2, 0, 2, ' ', 0)
+	for _, result := range resp.Addresses {
+		if result.Status != C.ResponseStatusSuccess {
+			fmt.Fprintf(tab, "\t%v\t%v\t%v\r\n", result.Addr, result.Family, cRed.Sprintf("%v: %v", result.Status, result.Message))
+			continue
+		}
+		fmt.Fprintf(tab, "\t%v\t%v\t%v\r\n", result.Addr, result.Family, queryLine("", *result.Query))
+	}
+	tab.Flush()
 
-	useCache, err := strconv.ParseBool(c.DefaultQuery("cache", "true"))
-	if err != nil {
-		c.AbortWithStatus(http.StatusBadRequest)
-		return
+	return txt.String()
+}
+
+// Join the privacy flags which are true, like "Proxy, Hosting".
+func privacyFlags(p response.Privacy) string {
+	var flags []string
+	if p.Proxy {
+		flags = append(flags, "Proxy")
 	}
+	if p.VPN {
+		flags = append(flags, "VPN")
+	}
+	if p.Tor {
+		flags = append(flags, "Tor")
+	}
+	if p.Hosting {
+		flags = append(flags, "Hosting")
+	}
+	if p.Mobile {
+		flags = append(flags, "Mobile")
+	}
+
+	return strings.Join(flags, ", ")
+}
 
-	var resp response.Query
+// Get the Query from the cache, or fetch it if not found or useCache is false.
+func cachedQuery(ctx context.Context, addrStr string, useCache bool) (response.Query, error) {
 	// love cache ^_^
-	if val, found := queryCache.Get(addrStr); found && useCache {
-		resp = val.(response.Query)
-		c.JSON(http.StatusOK, resp)
-		return
+	if useCache {
+		if resp, found := queryCache.Get(ctx, addrStr); found {
+			return resp, nil
+		}
 	}
 
+	return fetchQuery(ctx, addrStr)
+}
+
+// Fetch the Query from upstream and complete it with local data, then save it to the cache.
+// Fields that are changing over time won't be filled, see geo.FillTime().
+func fetchQuery(ctx context.Context, addrStr string) (response.Query, error) {
+	// addrStr is already validated by parseQuery()
+	addr := netip.MustParseAddr(addrStr)
+
+	// lookup hostname while waiting for upstream
+	hostname := make(chan string, 1)
+	go func() {
+		hostname <- lookupHostname(ctx, addr)
+	}()
+
 	api, err := upstream.SelectAPI(conf.Upstream)
 	if err != nil {
 		log.Fatalf("Can't select API: %v", err)
-		c.AbortWithStatus(http.StatusInternalServerError)
-		return
 	}
 
-	resp, err = api.Fetch(ctx, addrStr)
+	resp, err := api.Fetch(ctx, addrStr)
 	if err != nil {
-		log.Printf("Upstream error: %v", err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-			"status":  C.ResponseStatusFailure,
-			"message": "Upstream error",
-		})
-		return
+		return resp, err
+	}
+
+	geo.FillCountry(&resp)
+	geo.FillRegion(&resp)
+	privacy.Fill(&resp, addr)
+
+	// prefer the local result, the upstream hostname is only used if it's also forward-confirmed
+	if name := <-hostname; len(name) > 0 {
+		resp.Hostname = name
+	} else {
+		resp.Hostname = confirmHostname(ctx, resp.Hostname, addr)
 	}
 
+	resp.Family = addrFamily(addr)
 	resp.Status = C.ResponseStatusSuccess
 
-	queryCache.SetDefault(addrStr, resp)
+	queryCache.Set(ctx, addrStr, resp)
+
+	return resp, nil
+}
+
+// Reverse DNS lookup with its own timeout, return empty string if disabled or failed.
+func lookupHostname(ctx context.Context, addr netip.Addr) string {
+	if !conf.RDNS.Enabled {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, conf.RDNS.Timeout)
+	defer cancel()
+
+	name, err := rdns.Lookup(ctx, addr)
+	if err != nil {
+		debug.Logger.Printf("Reverse DNS lookup failure: %v", err)
+		return ""
+	}
+
+	return name
+}
+
+// Return name if it resolves back to addr, otherwise empty string. Uses the timeout of reverse DNS lookup.
+func confirmHostname(ctx context.Context, name string, addr netip.Addr) string {
+	if !conf.RDNS.Enabled || len(name) == 0 {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, conf.RDNS.Timeout)
+	defer cancel()
+
+	if !rdns.Confirm(ctx, name, addr) {
+		debug.Logger.Printf("Upstream hostname %v isn't forward-confirmed", name)
+		return ""
+	}
+	return name
+}
+
+// Get the moment for time related fields from "at" query string, default is now.
+func parseAt(c *gin.Context) (time.Time, error) {
+	atStr := c.Query("at")
+	if atStr == "" {
+		return time.Now(), nil
+	}
+
+	return time.Parse(time.RFC3339, atStr)
+}
+
+// Get the wanted address family of domain from "family" query string, 0 means no requirement.
+func parseFamily(c *gin.Context) (int, error) {
+	switch c.Query("family") {
+	case "":
+		return 0, nil
+	case "4":
+		return 4, nil
+	case "6":
+		return 6, nil
+	default:
+		return 0, errors.New("unknown family")
+	}
+}
+
+// Pull the host out of the user input, which can be a URL, "host:port", or bracketed IPv6 address.
+// IPv4-mapped IPv6 address is unwrapped, IPv6 zone ID is rejected, domain is converted to the ASCII form.
+// e.g. "https://Example.com/path" -> "example.com", "[::ffff:1.2.3.4]:8080" -> "1.2.3.4", "bücher.de" -> "xn--bcher-kva.de"
+func normalizeQuery(query string) (string, error) {
+	query = strings.TrimSpace(query)
+
+	// URL, with or without scheme, also the one only has userinfo like "user@example.com"
+	if strings.ContainsAny(query, "/@") {
+		if !strings.Contains(query, "://") {
+			query = "//" + query
+		}
+		u, err := url.Parse(query)
+		if err != nil {
+			return "", fmt.Errorf("bad URL: %w", err)
+		}
+		query = u.Host
+	}
+
+	if host, _, err := net.SplitHostPort(query); err == nil {
+		query = host
+	} else if strings.HasPrefix(query, "[") && strings.HasSuffix(query, "]") {
+		query = query[1 : len(query)-1]
+	}
+
+	if query == "" {
+		return "", errors.New("empty query")
+	}
+
+	addr, err := netip.ParseAddr(query)
+	if err == nil {
+		if addr.Zone() != "" {
+			return "", errors.New("IPv6 zone ID is not allowed")
+		}
+		return addr.Unmap().String(), nil
+	}
+
+	// UTS #46, to the ASCII form(punycode) that is used by the suffix check and resolver.
+	// it's also case-folded
+	ascii, err := idnaProfile.ToASCII(strings.TrimSuffix(query, "."))
+	if err != nil {
+		return "", &domainNameError{Reason: strings.TrimPrefix(err.Error(), "idna: ")}
+	}
+	if i := strings.IndexFunc(ascii, notHostnameRune); i >= 0 {
+		return "", &domainNameError{Reason: fmt.Sprintf("disallowed rune %q", ascii[i])}
+	}
+	return ascii, nil
+}
+
+// The IDNA profile of domain queries, DNS length limits are checked too.
+// The STD3 rules are checked by notHostnameRune() instead, they reject the underscore in names like "_dmarc.example.com".
+var idnaProfile = idna.New(idna.MapForLookup(), idna.StrictDomainName(false), idna.BidiRule(), idna.VerifyDNSLength(true))
+
+// Letters, digits, hyphen and dot are allowed in the ASCII form of hostnames, also the underscore.
+func notHostnameRune(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '.', r == '_':
+		return false
+	default:
+		return true
+	}
+}
+
+// The domain name is rejected by IDNA, the reason is shown to the client.
+type domainNameError struct {
+	Reason string
+}
 
-	c.JSON(http.StatusOK, resp)
+func (e *domainNameError) Error() string {
+	return "bad domain name, " + e.Reason
+}
+
+// Return the Unicode and ASCII forms of a normalized query, the ASCII form is empty for IP address.
+func queryForms(query string) (string, string) {
+	if _, err := netip.ParseAddr(query); err == nil {
+		return query, ""
+	}
+
+	unicode, err := idnaProfile.ToUnicode(query)
+	if err != nil {
+		return query, query
+	}
+	return unicode, query
 }
 
 // Convert query string that can contain IP address and domain into one safe IP address format.
 // Result won't be: empty string, invalid IP, unresolvable domain.
-func parseQuery(query string) (addrStr string, err error) {
+// The family only affects domain, see lookupDomain().
+func parseQuery(ctx context.Context, query string, family int) (addrStr string, err error) {
 	if query == "" {
 		return "", errors.New("empty query")
 	}
@@ -308,8 +985,10 @@ func parseQuery(query string) (addrStr string, err error) {
 	// return the query if it's a real IP address
 	addrIP, err := netip.ParseAddr(query)
 	if err == nil {
-		if isSpecialAddr(addrIP) {
-			return "", errors.New("IP address is in invalid range")
+		// still return the address, it's used by the reserved mode
+		err = special.Check(addrIP)
+		if err != nil {
+			return query, err
 		}
 		return query, nil
 	}
@@ -320,44 +999,124 @@ func parseQuery(query string) (addrStr string, err error) {
 	}
 
 	// query is a domain name, resolve it
-	addrStr, err = resolveDomain(query)
+	addrStrArr, err := lookupDomain(ctx, query, family, conf.Domain.RejectAnySpecial)
 	if err != nil {
-		return addrStr, err
+		return "", err
 	}
+	addrStr = addrStrArr[0]
 
 	addrIP, err = netip.ParseAddr(addrStr)
 	if err != nil {
 		return addrStr, err
 	}
-	if isSpecialAddr(addrIP) {
-		return "", errors.New("IP address is in invalid range")
+	err = special.Check(addrIP)
+	if err != nil {
+		return addrStr, err
 	}
 
 	return addrStr, nil
 }
 
-func resolveDomain(domain string) (addrStr string, err error) {
+var errDomainDenied = errors.New("domain is denied")
+
+// Resolve all the addresses of domain, the blocked suffixes and domain rules are checked first.
+// Only the records of family are resolved if it's 4 or 6,
+// otherwise the addresses of domain.prefer_family are sorted to the front.
+//
+// With rejectAnySpecial, the domain is denied if any of its answers is a rejected special-purpose address,
+// or a domain with both public and private addresses can probe the internal DNS view.
+// The address list doesn't use it, each address there has its own rejected status.
+// At most domain.max_answers addresses are returned.
+func lookupDomain(ctx context.Context, domain string, family int, rejectAnySpecial bool) ([]string, error) {
+	// the DNS resolvers would take it as a name
+	if _, err := netip.ParseAddr(domain); err == nil {
+		return nil, fmt.Errorf("%v is an IP address, not a domain", domain)
+	}
+
 	// check its suffix
 	suffix, _ := publicsuffix.PublicSuffix(domain)
 	if slices.Contains(conf.Domain.BlockSuffix, suffix) {
-		return "", errors.New("invalid domain suffix")
+		return nil, errors.New("invalid domain suffix")
+	}
+
+	allowed, rule := domainrule.Check(domain)
+	if !allowed {
+		if rule != nil {
+			return nil, fmt.Errorf("%w, %v by rule %v", errDomainDenied, domain, rule)
+		}
+		return nil, fmt.Errorf("%w, %v matched no rule in allowlist mode", errDomainDenied, domain)
 	}
 
-	addrStrArr, err := net.LookupHost(domain)
+	network := "ip"
+	switch family {
+	case 4:
+		network = "ip4"
+	case 6:
+		network = "ip6"
+	}
+
+	addrs, _, err := domainResolver.Lookup(ctx, network, domain)
 	if err != nil {
-		return "", fmt.Errorf("lookup domain failure: %w", err)
+		return nil, fmt.Errorf("lookup domain failure: %w", err)
+	}
+	if len(addrs) == 0 {
+		return nil, errors.New("no address of domain")
 	}
-	addrStr = addrStrArr[0]
 
-	return addrStr, nil
+	// check all the answers before the cap, the dropped ones are still a part of the domain
+	if rejectAnySpecial {
+		var offending []string
+		for _, addr := range addrs {
+			addr = addr.Unmap()
+			if _, overridden := override.Lookup(addr); overridden {
+				continue
+			}
+			if special.Check(addr) != nil {
+				offending = append(offending, addr.String())
+			}
+		}
+		if len(offending) > 0 {
+			return nil, fmt.Errorf("%w, %v resolves to special-purpose addresses: %v", errDomainDenied, domain, strings.Join(offending, ", "))
+		}
+	}
+
+	if family == 0 && conf.Domain.PreferFamily != 0 {
+		prefer := fmt.Sprintf("IPv%v", conf.Domain.PreferFamily)
+		// keep the resolver order in the same family
+		slices.SortStableFunc(addrs, func(a, b netip.Addr) int {
+			aPrefer := addrFamily(a) == prefer
+			bPrefer := addrFamily(b) == prefer
+			switch {
+			case aPrefer && !bPrefer:
+				return -1
+			case !aPrefer && bPrefer:
+				return 1
+			default:
+				return 0
+			}
+		})
+	}
+
+	if len(addrs) > conf.Domain.MaxAnswers {
+		debug.Logger.Printf("%v has %v answers, only the first %v are used", domain, len(addrs), conf.Domain.MaxAnswers)
+		addrs = addrs[:conf.Domain.MaxAnswers]
+	}
+
+	addrStrArr := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		// the addresses from hosts file may be IPv4-mapped IPv6
+		addrStrArr = append(addrStrArr, addr.Unmap().String())
+	}
+
+	return addrStrArr, nil
 }
 
-// Check if the given IP is one of loopback, private, unspecified(0.0.0.0), or any non-global unicast address.
-func isSpecialAddr(addr netip.Addr) bool {
-	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() || !addr.IsGlobalUnicast() {
-		return true
+// Return "IPv4" or "IPv6".
+func addrFamily(addr netip.Addr) string {
+	if addr.Unmap().Is4() {
+		return "IPv4"
 	}
-	return false
+	return "IPv6"
 }
 
 func utcOffsetToISO8601(min int) string {
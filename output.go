@@ -0,0 +1,466 @@
+package main
+
+import (
+	"embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	C "github.com/SourLemonJuice/ipapi-agent/constant"
+	"github.com/SourLemonJuice/ipapi-agent/response"
+)
+
+const (
+	formatText = "text"
+	formatJSON = "json"
+	formatYAML = "yaml"
+	formatXML  = "xml"
+	formatCSV  = "csv"
+	formatHTML = "html"
+	// one line for status bars
+	formatLine = "line"
+	// KEY=value lines for shell eval
+	formatShell = "shell"
+	// only used by the single-field endpoints
+	formatField = "field"
+)
+
+// Media types of each format, used to match the Accept header.
+var formatMediaTypes = map[string][]string{
+	formatText: {"text/plain"},
+	formatJSON: {"application/json"},
+	formatYAML: {"application/yaml", "application/x-yaml", "text/yaml"},
+	formatXML:  {"application/xml", "text/xml"},
+	formatCSV:  {"text/csv"},
+	formatHTML: {"text/html"},
+	// not in formatOrder, so only chosen by "format" query string or path suffix
+	formatLine:  {"text/plain"},
+	formatShell: {"text/plain"},
+}
+
+// Order to try when matching a wildcard media type like "text/*".
+var formatOrder = []string{formatText, formatJSON, formatYAML, formatXML, formatCSV, formatHTML}
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+// The HTML pages, no external assets are used.
+var htmlTemplate = template.Must(template.New("").Funcs(template.FuncMap{
+	"utcOffsetToISO8601": utcOffsetToISO8601,
+	"privacyFlags":       privacyFlags,
+}).ParseFS(templateFS, "templates/*.html"))
+
+// Per-request output options.
+type output struct {
+	format   string
+	colorful bool
+	ascii    bool   // replace the non-ASCII symbols in text output, for legacy consoles
+	field    string // name of the field in formatField
+}
+
+// Pick the output format from "format" query string, or the Accept header.
+// Also the text options: "color" and "ascii" query strings.
+// The returned output is always usable, it falls back to defaults when error.
+func newOutput(c *gin.Context, defFormat string) (output, error) {
+	out := output{
+		format: defFormat,
+	}
+
+	var err error
+	out.ascii, err = strconv.ParseBool(c.DefaultQuery("ascii", "false"))
+	if err != nil {
+		return out, fmt.Errorf("bad ascii option: %w", err)
+	}
+
+	out.colorful, err = colorMode(c)
+	if err != nil {
+		return out, err
+	}
+
+	if format := c.Query("format"); format != "" {
+		if _, ok := formatMediaTypes[format]; !ok {
+			return out, fmt.Errorf("unknown format '%v'", format)
+		}
+		out.format = format
+		return out, nil
+	}
+
+	out.format = negotiateFormat(c.GetHeader("Accept"), defFormat)
+	return out, nil
+}
+
+// Decide whether to use color in text output.
+// "color=always|never" query string first, then the "No-Color" header, last is the User-Agent patterns.
+func colorMode(c *gin.Context) (bool, error) {
+	switch c.DefaultQuery("color", "auto") {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+	default:
+		return false, errors.New("unknown color option")
+	}
+
+	// like the NO_COLOR environment variable, any non-empty value disables color
+	if len(c.GetHeader("No-Color")) > 0 {
+		return false, nil
+	}
+
+	userAgent := c.GetHeader("User-Agent")
+	return slices.ContainsFunc(conf.Text.ColorUserAgents, func(pattern string) bool {
+		return matchWildcard(pattern, userAgent)
+	}), nil
+}
+
+// Case-insensitive match, "*" in the pattern matches any characters, including none.
+func matchWildcard(pattern string, str string) bool {
+	pattern = strings.ToLower(pattern)
+	str = strings.ToLower(str)
+
+	parts := strings.Split(pattern, "*")
+	// no wildcard at all
+	if len(parts) == 1 {
+		return pattern == str
+	}
+
+	if !strings.HasPrefix(str, parts[0]) {
+		return false
+	}
+	str = str[len(parts[0]):]
+
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		idx := strings.Index(str, part)
+		if idx < 0 {
+			return false
+		}
+		str = str[idx+len(part):]
+	}
+
+	return strings.HasSuffix(str, last)
+}
+
+// Respond the successful Query.
+func (out output) query(c *gin.Context, addrStr string, resp response.Query) {
+	switch out.format {
+	case formatText:
+		txt, err := textQuery(out, addrStr, resp)
+		if err != nil {
+			log.Print(err)
+			out.failure(c, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+		c.String(http.StatusOK, txt)
+	case formatJSON:
+		c.JSON(http.StatusOK, resp)
+	case formatYAML:
+		c.YAML(http.StatusOK, resp)
+	case formatXML:
+		c.XML(http.StatusOK, resp)
+	case formatCSV:
+		respCSV(c, http.StatusOK, queryCSV(resp))
+	case formatHTML:
+		c.HTML(http.StatusOK, "query.html", gin.H{
+			"Addr": addrStr,
+			"Resp": resp,
+		})
+	case formatField:
+		// use LF, the shell command substitution only removes trailing LF
+		c.String(http.StatusOK, queryFields[out.field](addrStr, resp)+"\n")
+	case formatLine:
+		c.String(http.StatusOK, queryLine(addrStr, resp)+"\n")
+	case formatShell:
+		c.String(http.StatusOK, shellExport(queryShell(addrStr, resp)))
+	}
+}
+
+// Respond all the resolved addresses of a domain, only text, JSON, YAML, XML and HTML are supported.
+func (out output) domain(c *gin.Context, resp response.Domain) {
+	switch out.format {
+	case formatText:
+		c.String(http.StatusOK, respTXTDomain(out, resp))
+	case formatHTML:
+		c.HTML(http.StatusOK, "domain.html", resp)
+	case formatJSON:
+		c.JSON(http.StatusOK, resp)
+	case formatYAML:
+		c.YAML(http.StatusOK, resp)
+	case formatXML:
+		c.XML(http.StatusOK, resp)
+	}
+}
+
+// Respond the failure message and abort the context.
+func (out output) failure(c *gin.Context, code int, message string) {
+	c.Abort()
+
+	resp := response.Failure{
+		Status:  C.ResponseStatusFailure,
+		Message: message,
+	}
+
+	switch out.format {
+	case formatText:
+		c.String(code, textFailure(out, message))
+	case formatField:
+		c.String(code, respTXTFailure(out, "%v", message))
+	case formatJSON:
+		c.JSON(code, resp)
+	case formatYAML:
+		c.YAML(code, resp)
+	case formatXML:
+		c.XML(code, resp)
+	case formatCSV:
+		respCSV(c, code, [][2]string{
+			{"status", resp.Status},
+			{"message", resp.Message},
+		})
+	case formatHTML:
+		c.HTML(code, "failure.html", resp)
+	case formatLine:
+		c.String(code, "FAILURE: %v\n", message)
+	case formatShell:
+		c.String(code, shellExport([][2]string{
+			{"IP_STATUS", resp.Status},
+			{"IP_MESSAGE", resp.Message},
+		}))
+	}
+}
+
+// Respond CSV with a header row and a value row, fields are name-value pairs.
+func respCSV(c *gin.Context, code int, fields [][2]string) {
+	var header, values []string
+	for _, v := range fields {
+		header = append(header, v[0])
+		values = append(values, v[1])
+	}
+
+	var txt strings.Builder
+	w := csv.NewWriter(&txt)
+	w.UseCRLF = true
+	w.Write(header)
+	w.Write(values)
+	w.Flush()
+
+	c.Data(code, "text/csv; charset=utf-8", []byte(txt.String()))
+}
+
+// Flatten the Query, nested objects use dot-separated names like "as.number".
+func queryCSV(resp response.Query) [][2]string {
+	var reserved response.Reserved
+	if resp.Reserved != nil {
+		reserved = *resp.Reserved
+	}
+
+	return [][2]string{
+		{"status", resp.Status},
+		{"query", resp.Query},
+		{"queryASCII", resp.QueryASCII},
+		{"dataSource", resp.DataSource},
+		{"hostname", resp.Hostname},
+		{"family", resp.Family},
+		{"country", resp.Country},
+		{"countryCode", resp.CountryCode},
+		{"countryAlpha3", resp.CountryAlpha3},
+		{"countryNumeric", resp.CountryNumeric},
+		{"countryFlag", resp.CountryFlag},
+		{"capital", resp.Capital},
+		{"callingCode", resp.CallingCode},
+		{"currency", resp.Currency},
+		{"tld", resp.TLD},
+		{"subregion", resp.Subregion},
+		{"region", resp.Region},
+		{"regionCode", resp.RegionCode},
+		{"timezone", resp.Timezone},
+		{"utcOffset", strconv.Itoa(resp.UTCOffset)},
+		{"isDST", strconv.FormatBool(resp.IsDST)},
+		{"localTime", resp.LocalTime},
+		{"org", resp.Org},
+		{"isp", resp.ISP},
+		{"asn", resp.ASN},
+		{"as.number", strconv.FormatUint(uint64(resp.AS.Number), 10)},
+		{"as.name", resp.AS.Name},
+		{"as.route", resp.AS.Route},
+		{"anycast", strconv.FormatBool(resp.Anycast)},
+		{"privacy.proxy", strconv.FormatBool(resp.Privacy.Proxy)},
+		{"privacy.vpn", strconv.FormatBool(resp.Privacy.VPN)},
+		{"privacy.tor", strconv.FormatBool(resp.Privacy.Tor)},
+		{"privacy.hosting", strconv.FormatBool(resp.Privacy.Hosting)},
+		{"privacy.mobile", strconv.FormatBool(resp.Privacy.Mobile)},
+		{"reserved.name", reserved.Name},
+		{"reserved.description", reserved.Description},
+		{"reserved.prefix", reserved.Prefix},
+		{"reserved.rfc", strings.Join(reserved.RFC, " ")},
+	}
+}
+
+// The compact one-line format, empty values are skipped.
+// e.g. "1.1.1.1 AU Queensland AS13335 Cloudflare, Inc."
+func queryLine(addrStr string, resp response.Query) string {
+	values := []string{addrStr, resp.CountryCode, resp.Region, resp.ASN, resp.Org}
+	if resp.Reserved != nil {
+		values = append(values, resp.Reserved.Description)
+	}
+	values = slices.DeleteFunc(values, func(v string) bool {
+		return len(v) == 0
+	})
+
+	return strings.Join(values, " ")
+}
+
+// Variables of the shell format.
+func queryShell(addrStr string, resp response.Query) [][2]string {
+	var reserved string
+	if resp.Reserved != nil {
+		reserved = resp.Reserved.Description
+	}
+
+	return [][2]string{
+		{"IP_STATUS", resp.Status},
+		{"IP_QUERY", resp.Query},
+		{"IP_QUERY_ASCII", resp.QueryASCII},
+		{"IP_ADDR", addrStr},
+		{"IP_HOSTNAME", resp.Hostname},
+		{"IP_FAMILY", resp.Family},
+		{"IP_DATA_SOURCE", resp.DataSource},
+		{"IP_COUNTRY", resp.Country},
+		{"IP_COUNTRY_CODE", resp.CountryCode},
+		{"IP_REGION", resp.Region},
+		{"IP_REGION_CODE", resp.RegionCode},
+		{"IP_TIMEZONE", resp.Timezone},
+		{"IP_UTC_OFFSET", strconv.Itoa(resp.UTCOffset)},
+		{"IP_ORG", resp.Org},
+		{"IP_ISP", resp.ISP},
+		{"IP_ASN", resp.ASN},
+		{"IP_AS_NUMBER", strconv.FormatUint(uint64(resp.AS.Number), 10)},
+		{"IP_AS_NAME", resp.AS.Name},
+		{"IP_RESERVED", reserved},
+	}
+}
+
+// One KEY='value' per line, which can be used with eval in POSIX shell.
+// Values are always single-quoted, a single quote inside closes the quoting, is escaped, then reopens it:
+//
+//	'\''
+func shellExport(vars [][2]string) string {
+	var txt strings.Builder
+	for _, v := range vars {
+		txt.WriteString(v[0])
+		txt.WriteString("='")
+		txt.WriteString(strings.ReplaceAll(v[1], "'", `'\''`))
+		txt.WriteString("'\n")
+	}
+	return txt.String()
+}
+
+// Values of the single-field endpoints.
+var queryFields = map[string]func(addrStr string, resp response.Query) string{
+	"ip":          func(addrStr string, _ response.Query) string { return addrStr },
+	"hostname":    func(_ string, resp response.Query) string { return resp.Hostname },
+	"country":     func(_ string, resp response.Query) string { return resp.Country },
+	"countryCode": func(_ string, resp response.Query) string { return resp.CountryCode },
+	"region":      func(_ string, resp response.Query) string { return resp.Region },
+	"regionCode":  func(_ string, resp response.Query) string { return resp.RegionCode },
+	"timezone":    func(_ string, resp response.Query) string { return resp.Timezone },
+	"utcOffset":   func(_ string, resp response.Query) string { return strconv.Itoa(resp.UTCOffset) },
+	"org":         func(_ string, resp response.Query) string { return resp.Org },
+	"isp":         func(_ string, resp response.Query) string { return resp.ISP },
+	"asn":         func(_ string, resp response.Query) string { return resp.ASN },
+}
+
+// Choose a format by the Accept header, honor the quality values(q=).
+// defFormat is used when the header is empty, "*/*", or nothing matched.
+func negotiateFormat(accept string, defFormat string) string {
+	for _, mediaType := range parseAccept(accept) {
+		format, err := matchMediaType(mediaType, defFormat)
+		if err == nil {
+			return format
+		}
+	}
+
+	return defFormat
+}
+
+func matchMediaType(mediaType string, defFormat string) (string, error) {
+	if mediaType == "*/*" {
+		return defFormat, nil
+	}
+	// browsers prefer text/html, only give them HTML on the human interface
+	if mediaType == "text/html" && defFormat != formatText {
+		return defFormat, nil
+	}
+
+	mainType, subType, _ := strings.Cut(mediaType, "/")
+	// try the default one first
+	for _, format := range append([]string{defFormat}, formatOrder...) {
+		for _, v := range formatMediaTypes[format] {
+			if v == mediaType {
+				return format, nil
+			}
+			if subType == "*" && strings.HasPrefix(v, mainType+"/") {
+				return format, nil
+			}
+		}
+	}
+
+	return "", errors.New("no format matched")
+}
+
+// Parse Accept header into media types, sorted by quality from high to low, q=0 is dropped.
+// e.g. "text/html,application/xml;q=0.9,*/*;q=0.8"
+func parseAccept(accept string) []string {
+	type entry struct {
+		mediaType string
+		quality   float64
+	}
+
+	var entries []entry
+	for part := range strings.SplitSeq(accept, ",") {
+		mediaType, params, _ := strings.Cut(part, ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+		if len(mediaType) == 0 {
+			continue
+		}
+
+		quality := 1.0
+		for param := range strings.SplitSeq(params, ";") {
+			key, val, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if key == "q" {
+				q, err := strconv.ParseFloat(val, 64)
+				if err == nil {
+					quality = q
+				}
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+
+		entries = append(entries, entry{mediaType, quality})
+	}
+
+	slices.SortStableFunc(entries, func(a, b entry) int {
+		switch {
+		case a.quality > b.quality:
+			return -1
+		case a.quality < b.quality:
+			return 1
+		}
+		return 0
+	})
+
+	var mediaTypes []string
+	for _, v := range entries {
+		mediaTypes = append(mediaTypes, v.mediaType)
+	}
+	return mediaTypes
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	C "github.com/SourLemonJuice/ipapi-agent/constant"
+	"github.com/SourLemonJuice/ipapi-agent/response"
+)
+
+func TestParseAccept(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   []string
+	}{
+		{"", nil},
+		{"application/json", []string{"application/json"}},
+		{"text/html,application/xml;q=0.9,*/*;q=0.8", []string{"text/html", "application/xml", "*/*"}},
+		// sorted by quality, the same quality keeps the order
+		{"text/plain;q=0.5, application/json, text/csv;q=0.5", []string{"application/json", "text/plain", "text/csv"}},
+		// q=0 means not acceptable
+		{"application/json;q=0, text/plain", []string{"text/plain"}},
+		{"application/json;q=0.0", nil},
+		// bad q is ignored, treated as 1
+		{"text/csv;q=abc, text/plain;q=0.9", []string{"text/csv", "text/plain"}},
+		{"Application/JSON ; charset=utf-8", []string{"application/json"}},
+		{" , ,text/plain", []string{"text/plain"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			got := parseAccept(tt.accept)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("parseAccept(%q) = %q, want %q", tt.accept, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		accept    string
+		defFormat string
+		want      string
+	}{
+		{"", formatJSON, formatJSON},
+		{"*/*", formatJSON, formatJSON},
+		{"*/*", formatText, formatText},
+		{"application/json", formatText, formatJSON},
+		{"application/x-yaml", formatJSON, formatYAML},
+		{"text/xml", formatJSON, formatXML},
+		{"text/csv", formatJSON, formatCSV},
+		// type wildcard, the default format goes first if it matches
+		{"text/*", formatJSON, formatText},
+		{"text/*", formatText, formatText},
+		{"application/*", formatText, formatJSON},
+		// browsers only get HTML on the human interface
+		{"text/html,application/xhtml+xml,*/*;q=0.8", formatText, formatHTML},
+		{"text/html,application/xhtml+xml,*/*;q=0.8", formatJSON, formatJSON},
+		// q-values decide the order
+		{"application/json;q=0.5, application/xml", formatText, formatXML},
+		{"application/json;q=0, text/csv;q=0.1", formatText, formatCSV},
+		// nothing acceptable is known
+		{"image/png", formatJSON, formatJSON},
+		{"application/json;q=0", formatText, formatText},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept+" "+tt.defFormat, func(t *testing.T) {
+			got := negotiateFormat(tt.accept, tt.defFormat)
+			if got != tt.want {
+				t.Errorf("negotiateFormat(%q, %q) = %q, want %q", tt.accept, tt.defFormat, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewOutputFormat(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		query   string
+		accept  string
+		want    string
+		wantErr bool
+	}{
+		{"accept header", "", "application/xml", formatXML, false},
+		{"format query string first", "?format=yaml", "application/xml", formatYAML, false},
+		{"format without media type matching", "?format=shell", "application/json", formatShell, false},
+		{"unknown format", "?format=toml", "application/json", formatJSON, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/"+tt.query, nil)
+			c.Request.Header.Set("Accept", tt.accept)
+
+			out, err := newOutput(c, formatJSON)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newOutput() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if out.format != tt.want {
+				t.Errorf("newOutput() format = %q, want %q", out.format, tt.want)
+			}
+		})
+	}
+}
+
+func TestDomainHTML(t *testing.T) {
+	resp := response.Domain{
+		Status:      C.ResponseStatusSuccess,
+		Domain:      "bücher.example",
+		DomainASCII: "xn--bcher-kva.example",
+		Addresses: []response.DomainAddr{
+			{Addr: "192.0.2.1", Family: "IPv4", Status: C.ResponseStatusSuccess, Query: &response.Query{CountryCode: "AU", Org: "Example Org"}},
+			{Addr: "10.0.0.1", Family: "IPv4", Status: C.ResponseStatusRejected, Message: "Private-Use"},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := htmlTemplate.ExecuteTemplate(&buf, "domain.html", resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"xn--bcher-kva.example", `href="/192.0.2.1"`, "Example Org", "rejected: Private-Use"} {
+		if !bytes.Contains(buf.Bytes(), []byte(want)) {
+			t.Errorf("domain.html doesn't contain %q", want)
+		}
+	}
+}
+
+func TestShellExportEval(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no sh to eval the output")
+	}
+
+	values := []string{
+		"",
+		"plain",
+		"it's",
+		"''",
+		`'\''`,
+		"$HOME ${PATH} $(echo injected) `echo injected`",
+		"line one\nline two\n",
+		`back\slash "double" \'`,
+		"Zürich ✓",
+	}
+
+	for _, value := range values {
+		t.Run(value, func(t *testing.T) {
+			script := shellExport([][2]string{{"IP_TEST", value}, {"IP_AFTER", "ok"}})
+			// print the variables back, the marker keeps the trailing newlines
+			out, err := exec.Command(sh, "-c", `eval "$1" && printf '%s|%s|' "$IP_TEST" "$IP_AFTER"`, "sh", script).Output()
+			if err != nil {
+				t.Fatalf("eval failure: %v, script:\n%v", err, script)
+			}
+
+			want := value + "|ok|"
+			if string(out) != want {
+				t.Errorf("eval got %q, want %q", out, want)
+			}
+		})
+	}
+}
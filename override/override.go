@@ -0,0 +1,84 @@
+package override
+
+import (
+	"net/netip"
+	"sync/atomic"
+
+	"github.com/SourLemonJuice/ipapi-agent/config"
+	C "github.com/SourLemonJuice/ipapi-agent/constant"
+	"github.com/SourLemonJuice/ipapi-agent/debug"
+	"github.com/SourLemonJuice/ipapi-agent/geo"
+	"github.com/SourLemonJuice/ipapi-agent/response"
+	"github.com/SourLemonJuice/ipapi-agent/upstream"
+)
+
+type entry struct {
+	prefix netip.Prefix
+	resp   response.Query
+}
+
+// Replaced as a whole when reloading, so the requests never see a half-loaded table.
+var table atomic.Pointer[[]entry]
+
+// Build the override table from config, the config should be validated already.
+func Load(conf []config.ConfigOverride) {
+	var entries []entry
+
+	for _, override := range conf {
+		resp := response.Query{
+			Status:      C.ResponseStatusSuccess,
+			DataSource:  override.DataSource,
+			Hostname:    override.Hostname,
+			CountryCode: override.CountryCode,
+			Region:      override.Region,
+			RegionCode:  override.RegionCode,
+			Timezone:    override.Timezone,
+			Org:         override.Org,
+			ISP:         override.ISP,
+			ASN:         override.ASN,
+			AS: response.AS{
+				Name: override.ASName,
+			},
+		}
+		if num, err := upstream.ASNumber(override.ASN); err == nil {
+			resp.AS.Number = num
+		}
+		geo.FillCountry(&resp)
+		geo.FillRegion(&resp)
+
+		for _, cidr := range override.CIDR {
+			entries = append(entries, entry{
+				prefix: netip.MustParsePrefix(cidr).Masked(),
+				resp:   resp,
+			})
+		}
+	}
+
+	table.Store(&entries)
+	debug.Logger.Printf("Overrides loaded, CIDRs: %v", len(entries))
+}
+
+// Return the Query of the longest matched CIDR.
+// Time related fields are not filled, see geo.FillTime().
+func Lookup(addr netip.Addr) (response.Query, bool) {
+	entries := table.Load()
+	if entries == nil {
+		return response.Query{}, false
+	}
+
+	addr = addr.WithZone("")
+	var found *entry
+	for i, e := range *entries {
+		if !e.prefix.Contains(addr) {
+			continue
+		}
+		if found == nil || e.prefix.Bits() > found.prefix.Bits() {
+			found = &(*entries)[i]
+		}
+	}
+
+	if found == nil {
+		return response.Query{}, false
+	}
+	return found.resp, true
+}
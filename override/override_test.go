@@ -0,0 +1,50 @@
+package override
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/SourLemonJuice/ipapi-agent/config"
+)
+
+func TestLookup(t *testing.T) {
+	t.Cleanup(func() {
+		Load(nil)
+	})
+
+	Load([]config.ConfigOverride{
+		{CIDR: []string{"10.0.0.0/8", "2001:db8::/32"}, DataSource: "office", CountryCode: "de", ASN: "AS64512", ASName: "Office"},
+		{CIDR: []string{"10.1.0.0/16"}, DataSource: "lab", CountryCode: "JP"},
+	})
+
+	tests := []struct {
+		addr       string
+		found      bool
+		dataSource string
+		asNumber   uint32
+	}{
+		{"10.9.1.1", true, "office", 64512},
+		{"2001:db8::1", true, "office", 64512},
+		// the longest prefix wins
+		{"10.1.2.3", true, "lab", 0},
+		{"192.0.2.1", false, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			resp, ok := Lookup(netip.MustParseAddr(tt.addr))
+			if ok != tt.found {
+				t.Fatalf("Lookup(%v) found %v, want %v", tt.addr, ok, tt.found)
+			}
+			if resp.DataSource != tt.dataSource || resp.AS.Number != tt.asNumber {
+				t.Errorf("Lookup(%v) = %v AS%v, want %v AS%v", tt.addr, resp.DataSource, resp.AS.Number, tt.dataSource, tt.asNumber)
+			}
+		})
+	}
+
+	// the country fields are filled
+	resp, _ := Lookup(netip.MustParseAddr("10.9.1.1"))
+	if resp.CountryCode != "DE" || resp.Country != "Germany" {
+		t.Errorf("got country %q %q, want DE Germany", resp.CountryCode, resp.Country)
+	}
+}
@@ -0,0 +1,114 @@
+package privacy
+
+import (
+	"bufio"
+	"fmt"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/SourLemonJuice/ipapi-agent/config"
+	"github.com/SourLemonJuice/ipapi-agent/debug"
+	"github.com/SourLemonJuice/ipapi-agent/response"
+)
+
+var (
+	torList []netip.Prefix
+	vpnList []netip.Prefix
+)
+
+// Load the local list files from config.
+func Load(conf config.ConfigPrivacy) error {
+	var err error
+
+	torList, err = loadList(conf.TorList)
+	if err != nil {
+		return fmt.Errorf("can't load Tor list: %w", err)
+	}
+
+	vpnList, err = loadList(conf.VPNList)
+	if err != nil {
+		return fmt.Errorf("can't load VPN list: %w", err)
+	}
+
+	debug.Logger.Printf("Privacy lists loaded, Tor: %v, VPN: %v", len(torList), len(vpnList))
+	return nil
+}
+
+// Merge the local lists into the privacy flags of Query, flags from upstream won't be cleared.
+func Fill(resp *response.Query, addr netip.Addr) {
+	if inList(torList, addr) {
+		resp.Privacy.Tor = true
+	}
+	if inList(vpnList, addr) {
+		resp.Privacy.VPN = true
+	}
+}
+
+func inList(list []netip.Prefix, addr netip.Addr) bool {
+	return slices.ContainsFunc(list, func(prefix netip.Prefix) bool {
+		return prefix.Contains(addr)
+	})
+}
+
+func loadList(paths []string) ([]netip.Prefix, error) {
+	var list []netip.Prefix
+
+	for _, path := range paths {
+		fileList, err := loadListFile(path)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, fileList...)
+	}
+
+	return list, nil
+}
+
+// File format: one IP address or CIDR per line, empty lines and lines start with "#" will be ignored.
+func loadListFile(path string) ([]netip.Prefix, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var list []netip.Prefix
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		prefix, err := parsePrefix(line)
+		if err != nil {
+			return nil, fmt.Errorf("%v:%v: %w", path, lineNum, err)
+		}
+		list = append(list, prefix)
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+
+	return list, nil
+}
+
+// Accept both CIDR and single IP address.
+func parsePrefix(str string) (netip.Prefix, error) {
+	if strings.Contains(str, "/") {
+		prefix, err := netip.ParsePrefix(str)
+		return prefix.Masked(), err
+	}
+
+	addr, err := netip.ParseAddr(str)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
@@ -0,0 +1,40 @@
+package rdns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// Lookup the PTR records of addr, return the first hostname that resolves back to addr(forward-confirmed).
+func Lookup(ctx context.Context, addr netip.Addr) (string, error) {
+	names, err := net.DefaultResolver.LookupAddr(ctx, addr.String())
+	if err != nil {
+		return "", err
+	}
+
+	for _, name := range names {
+		if Confirm(ctx, name, addr) {
+			return strings.TrimSuffix(name, "."), nil
+		}
+	}
+
+	return "", errors.New("no forward-confirmed hostname")
+}
+
+// Whether name resolves back to addr, for the hostnames from other sources like the upstream.
+func Confirm(ctx context.Context, name string, addr netip.Addr) bool {
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", name)
+	if err != nil {
+		return false
+	}
+
+	for _, v := range addrs {
+		if v.Unmap() == addr.Unmap() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package resolver
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+	"time"
+
+	"github.com/SourLemonJuice/ipapi-agent/cache"
+)
+
+// Cache the results of another resolver with their TTL, capped by maxTTL.
+// Failures are not cached, the least recently used hosts are evicted over maxEntries.
+type cachedResolver struct {
+	next   Resolver
+	maxTTL time.Duration
+	cache  *cache.Local[[]netip.Addr]
+}
+
+func newCachedResolver(next Resolver, maxTTL time.Duration, maxEntries int) *cachedResolver {
+	return &cachedResolver{
+		next:   next,
+		maxTTL: maxTTL,
+		cache:  cache.NewLocal[[]netip.Addr](maxEntries, 10*time.Minute),
+	}
+}
+
+func (r *cachedResolver) Lookup(ctx context.Context, network string, host string) ([]netip.Addr, time.Duration, error) {
+	key := network + " " + host
+	if cached, expiration, found := r.cache.Get(key); found {
+		// the caller may sort the slice
+		return slices.Clone(cached), time.Until(expiration), nil
+	}
+
+	addrs, ttl, err := r.next.Lookup(ctx, network, host)
+	if err != nil {
+		return addrs, ttl, err
+	}
+
+	ttl = min(ttl, r.maxTTL)
+	if ttl > 0 {
+		r.cache.Set(key, slices.Clone(addrs), ttl)
+	}
+	return addrs, ttl, nil
+}
@@ -0,0 +1,220 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/SourLemonJuice/ipapi-agent/config"
+	C "github.com/SourLemonJuice/ipapi-agent/constant"
+)
+
+// Lookup returns the addresses and how long they can be cached.
+// Network is one of "ip", "ip4" or "ip6", like net.Resolver.LookupNetIP().
+type Resolver interface {
+	Lookup(ctx context.Context, network string, host string) ([]netip.Addr, time.Duration, error)
+}
+
+// The system resolver doesn't tell the TTL, a fixed one from config is used.
+type systemResolver struct {
+	ttl time.Duration
+}
+
+func (r *systemResolver) Lookup(ctx context.Context, network string, host string) ([]netip.Addr, time.Duration, error) {
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, network, host)
+	return addrs, r.ttl, err
+}
+
+// The resolver talks to the configured servers itself.
+type dnsResolver struct {
+	protocol   string
+	servers    []string
+	disableECS bool
+	httpClient *http.Client
+}
+
+// Return the resolver of config, the system resolver is used when protocol is "system".
+// Results are cached unless cache_max_ttl is 0.
+func New(conf config.ConfigResolver) Resolver {
+	var r Resolver
+	if conf.Protocol == C.ResolverProtocolSystem {
+		r = &systemResolver{ttl: conf.SystemTTL}
+	} else {
+		r = &dnsResolver{
+			protocol:   conf.Protocol,
+			servers:    conf.Servers,
+			disableECS: conf.DisableECS,
+			httpClient: &http.Client{},
+		}
+	}
+
+	if conf.CacheMaxTTL > 0 {
+		r = newCachedResolver(r, conf.CacheMaxTTL, conf.CacheMaxEntries)
+	}
+	return r
+}
+
+// For "ip", the IPv4 addresses come first.
+// The TTL is the smallest one of the records, CNAMEs included.
+func (r *dnsResolver) Lookup(ctx context.Context, network string, host string) ([]netip.Addr, time.Duration, error) {
+	var types []dnsmessage.Type
+	switch network {
+	case "ip":
+		types = []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA}
+	case "ip4":
+		types = []dnsmessage.Type{dnsmessage.TypeA}
+	case "ip6":
+		types = []dnsmessage.Type{dnsmessage.TypeAAAA}
+	default:
+		return nil, 0, fmt.Errorf("unknown network '%v'", network)
+	}
+
+	name, err := dnsmessage.NewName(fqdn(host))
+	if err != nil {
+		return nil, 0, fmt.Errorf("bad domain name: %w", err)
+	}
+
+	// query A and AAAA at the same time
+	results := make([]chan lookupResult, len(types))
+	for i, qtype := range types {
+		results[i] = make(chan lookupResult, 1)
+		go func() {
+			addrs, ttl, err := r.lookup(ctx, name, qtype)
+			results[i] <- lookupResult{addrs, ttl, err}
+		}()
+	}
+
+	var addrs []netip.Addr
+	var ttl time.Duration
+	var errs []error
+	for _, ch := range results {
+		result := <-ch
+		if len(result.addrs) > 0 {
+			if len(addrs) == 0 || result.ttl < ttl {
+				ttl = result.ttl
+			}
+			addrs = append(addrs, result.addrs...)
+		}
+		if result.err != nil {
+			errs = append(errs, result.err)
+		}
+	}
+
+	if len(addrs) == 0 {
+		if len(errs) > 0 {
+			return nil, 0, errors.Join(errs...)
+		}
+		return nil, 0, fmt.Errorf("no address of %v", host)
+	}
+	return addrs, ttl, nil
+}
+
+type lookupResult struct {
+	addrs []netip.Addr
+	ttl   time.Duration
+	err   error
+}
+
+// Try the servers in order until one answers.
+func (r *dnsResolver) lookup(ctx context.Context, name dnsmessage.Name, qtype dnsmessage.Type) ([]netip.Addr, time.Duration, error) {
+	query, err := r.newQuery(name, qtype)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var errs []error
+	for _, server := range r.servers {
+		answer, err := r.exchange(ctx, server, query)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%v: %w", server, err))
+			// no time for the next server
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+
+		return parseAnswer(answer, qtype)
+	}
+
+	return nil, 0, errors.Join(errs...)
+}
+
+// Build the query message, the ID is set by exchange().
+func (r *dnsResolver) newQuery(name dnsmessage.Name, qtype dnsmessage.Type) (dnsmessage.Message, error) {
+	query := dnsmessage.Message{
+		Header: dnsmessage.Header{RecursionDesired: true},
+		Questions: []dnsmessage.Question{{
+			Name:  name,
+			Type:  qtype,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+
+	var opt dnsmessage.ResourceHeader
+	err := opt.SetEDNS0(udpPayloadSize, dnsmessage.RCodeSuccess, false)
+	if err != nil {
+		return query, err
+	}
+
+	var options []dnsmessage.Option
+	if r.disableECS {
+		// RFC 7871 section 7.1.2, source prefix 0 asks the server not to add the client subnet
+		options = append(options, dnsmessage.Option{
+			Code: optionCodeECS,
+			Data: []byte{0, 1, 0, 0}, // family IPv4, source prefix 0, scope prefix 0, no address
+		})
+	}
+
+	query.Additionals = append(query.Additionals, dnsmessage.Resource{
+		Header: opt,
+		Body:   &dnsmessage.OPTResource{Options: options},
+	})
+	return query, nil
+}
+
+// Collect the addresses of qtype, CNAME chain is already followed by the recursive server.
+func parseAnswer(answer dnsmessage.Message, qtype dnsmessage.Type) ([]netip.Addr, time.Duration, error) {
+	switch answer.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, 0, errors.New("no such host")
+	default:
+		return nil, 0, fmt.Errorf("server failure: %v", answer.RCode)
+	}
+
+	var addrs []netip.Addr
+	var ttl uint32
+	for i, res := range answer.Answers {
+		if i == 0 || res.Header.TTL < ttl {
+			ttl = res.Header.TTL
+		}
+
+		switch body := res.Body.(type) {
+		case *dnsmessage.AResource:
+			if qtype == dnsmessage.TypeA {
+				addrs = append(addrs, netip.AddrFrom4(body.A))
+			}
+		case *dnsmessage.AAAAResource:
+			if qtype == dnsmessage.TypeAAAA {
+				addrs = append(addrs, netip.AddrFrom16(body.AAAA))
+			}
+		}
+	}
+
+	return addrs, time.Duration(ttl) * time.Second, nil
+}
+
+func fqdn(host string) string {
+	if strings.HasSuffix(host, ".") {
+		return host
+	}
+	return host + "."
+}
@@ -0,0 +1,646 @@
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	C "github.com/SourLemonJuice/ipapi-agent/constant"
+)
+
+// Build the replies of a query, every returned message is sent in order.
+type handler func(query dnsmessage.Message) []dnsmessage.Message
+
+// A local DNS server on both UDP and TCP of the same port, like a stub resolver.
+type stub struct {
+	addr string
+	// messages for UDP and TCP, a nil one never replies
+	udp handler
+	tcp handler
+	// queries received, by any protocol
+	queries atomic.Int32
+	last    atomic.Pointer[dnsmessage.Message]
+}
+
+func newStub(t *testing.T, udp handler, tcp handler) *stub {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc, err := net.ListenPacket("udp", ln.Addr().String())
+	if err != nil {
+		ln.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ln.Close()
+		pc.Close()
+	})
+
+	s := &stub{addr: ln.Addr().String(), udp: udp, tcp: tcp}
+	go s.serveUDP(pc)
+	go s.serveTCP(ln)
+	return s
+}
+
+func (s *stub) receive(packet []byte) (dnsmessage.Message, bool) {
+	var query dnsmessage.Message
+	if query.Unpack(packet) != nil {
+		return query, false
+	}
+	s.queries.Add(1)
+	s.last.Store(&query)
+	return query, true
+}
+
+func (s *stub) serveUDP(pc net.PacketConn) {
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		query, ok := s.receive(buf[:n])
+		if !ok || s.udp == nil {
+			continue
+		}
+		for _, msg := range s.udp(query) {
+			packed, _ := msg.Pack()
+			pc.WriteTo(packed, addr)
+		}
+	}
+}
+
+func (s *stub) serveTCP(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+
+			var length uint16
+			if binary.Read(conn, binary.BigEndian, &length) != nil {
+				return
+			}
+			buf := make([]byte, length)
+			if _, err := io.ReadFull(conn, buf); err != nil {
+				return
+			}
+			query, ok := s.receive(buf)
+			if !ok || s.tcp == nil {
+				// keep the connection open until the client gives up
+				io.Copy(io.Discard, conn)
+				return
+			}
+			for _, msg := range s.tcp(query) {
+				packed, _ := msg.Pack()
+				conn.Write(binary.BigEndian.AppendUint16(nil, uint16(len(packed))))
+				conn.Write(packed)
+			}
+		}()
+	}
+}
+
+// Answer the A and AAAA queries with addrs of the same family, and an optional CNAME first.
+func answer(addrs []netip.Addr, ttl uint32, cname uint32) handler {
+	return func(query dnsmessage.Message) []dnsmessage.Message {
+		return []dnsmessage.Message{reply(query, addrs, ttl, cname)}
+	}
+}
+
+func reply(query dnsmessage.Message, addrs []netip.Addr, ttl uint32, cname uint32) dnsmessage.Message {
+	q := query.Questions[0]
+	msg := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: query.ID, Response: true, RecursionAvailable: true},
+		Questions: query.Questions,
+	}
+
+	target := q.Name
+	if cname > 0 {
+		target = dnsmessage.MustNewName("target.example.")
+		msg.Answers = append(msg.Answers, dnsmessage.Resource{
+			Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeCNAME, Class: dnsmessage.ClassINET, TTL: cname},
+			Body:   &dnsmessage.CNAMEResource{CNAME: target},
+		})
+	}
+
+	for _, addr := range addrs {
+		header := dnsmessage.ResourceHeader{Name: target, Class: dnsmessage.ClassINET, TTL: ttl}
+		switch {
+		case addr.Is4() && q.Type == dnsmessage.TypeA:
+			header.Type = dnsmessage.TypeA
+			msg.Answers = append(msg.Answers, dnsmessage.Resource{Header: header, Body: &dnsmessage.AResource{A: addr.As4()}})
+		case addr.Is6() && q.Type == dnsmessage.TypeAAAA:
+			header.Type = dnsmessage.TypeAAAA
+			msg.Answers = append(msg.Answers, dnsmessage.Resource{Header: header, Body: &dnsmessage.AAAAResource{AAAA: addr.As16()}})
+		}
+	}
+	return msg
+}
+
+func addrs(s ...string) []netip.Addr {
+	var out []netip.Addr
+	for _, v := range s {
+		out = append(out, netip.MustParseAddr(v))
+	}
+	return out
+}
+
+func newDNSResolver(protocol string, servers ...string) *dnsResolver {
+	return &dnsResolver{protocol: protocol, servers: servers, httpClient: &http.Client{}}
+}
+
+func lookup(t *testing.T, r Resolver, network string) ([]netip.Addr, time.Duration, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	return r.Lookup(ctx, network, "example.com")
+}
+
+func TestLookupProtocols(t *testing.T) {
+	want := addrs("192.0.2.1", "2001:db8::1")
+	s := newStub(t, answer(want, 300, 0), answer(want, 300, 0))
+
+	for _, protocol := range []string{C.ResolverProtocolUDP, C.ResolverProtocolTCP} {
+		t.Run(protocol, func(t *testing.T) {
+			got, _, err := lookup(t, newDNSResolver(protocol, s.addr), "ip")
+			if err != nil {
+				t.Fatal(err)
+			}
+			// IPv4 first
+			if !slices.Equal(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestLookupNetwork(t *testing.T) {
+	s := newStub(t, answer(addrs("192.0.2.1", "2001:db8::1"), 300, 0), nil)
+	r := newDNSResolver(C.ResolverProtocolUDP, s.addr)
+
+	tests := []struct {
+		network string
+		want    []netip.Addr
+	}{
+		{"ip4", addrs("192.0.2.1")},
+		{"ip6", addrs("2001:db8::1")},
+	}
+	for _, tt := range tests {
+		got, _, err := lookup(t, r, tt.network)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%v: got %v, want %v", tt.network, got, tt.want)
+		}
+	}
+
+	_, _, err := lookup(t, r, "tcp")
+	if err == nil {
+		t.Error("unknown network should fail")
+	}
+}
+
+func TestUDPIgnoresMismatchedAnswers(t *testing.T) {
+	want := addrs("192.0.2.1")
+	s := newStub(t, func(query dnsmessage.Message) []dnsmessage.Message {
+		badID := reply(query, addrs("198.51.100.1"), 300, 0)
+		badID.ID++
+
+		badQuestion := reply(query, addrs("198.51.100.2"), 300, 0)
+		badQuestion.Questions = []dnsmessage.Question{{
+			Name:  dnsmessage.MustNewName("other.example."),
+			Type:  query.Questions[0].Type,
+			Class: dnsmessage.ClassINET,
+		}}
+
+		badType := reply(query, addrs("198.51.100.3"), 300, 0)
+		badType.Questions = []dnsmessage.Question{{
+			Name:  query.Questions[0].Name,
+			Type:  dnsmessage.TypeMX,
+			Class: dnsmessage.ClassINET,
+		}}
+
+		notResponse := reply(query, addrs("198.51.100.4"), 300, 0)
+		notResponse.Response = false
+
+		return []dnsmessage.Message{badID, badQuestion, badType, notResponse, reply(query, want, 300, 0)}
+	}, nil)
+
+	got, _, err := lookup(t, newDNSResolver(C.ResolverProtocolUDP, s.addr), "ip4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestStreamRejectsMismatchedAnswer(t *testing.T) {
+	s := newStub(t, nil, func(query dnsmessage.Message) []dnsmessage.Message {
+		msg := reply(query, addrs("198.51.100.1"), 300, 0)
+		msg.ID++
+		return []dnsmessage.Message{msg}
+	})
+
+	_, _, err := lookup(t, newDNSResolver(C.ResolverProtocolTCP, s.addr), "ip4")
+	if err == nil {
+		t.Fatal("mismatched ID should fail")
+	}
+}
+
+func TestQuestionNameCaseInsensitive(t *testing.T) {
+	want := addrs("192.0.2.1")
+	// some servers randomize the case(0x20 encoding)
+	s := newStub(t, func(query dnsmessage.Message) []dnsmessage.Message {
+		msg := reply(query, want, 300, 0)
+		msg.Questions[0].Name = dnsmessage.MustNewName("ExAmPlE.CoM.")
+		return []dnsmessage.Message{msg}
+	}, nil)
+
+	got, _, err := lookup(t, newDNSResolver(C.ResolverProtocolUDP, s.addr), "ip4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestUDPTruncatedFallbackToTCP(t *testing.T) {
+	want := addrs("192.0.2.1", "192.0.2.2")
+	s := newStub(t, func(query dnsmessage.Message) []dnsmessage.Message {
+		msg := reply(query, nil, 300, 0)
+		msg.Truncated = true
+		return []dnsmessage.Message{msg}
+	}, answer(want, 300, 0))
+
+	got, _, err := lookup(t, newDNSResolver(C.ResolverProtocolUDP, s.addr), "ip4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// one over UDP, then one over TCP
+	if n := s.queries.Load(); n != 2 {
+		t.Errorf("got %v queries, want 2", n)
+	}
+}
+
+// Return the ECS option of the query, nil if not found.
+func ecsOption(t *testing.T, query *dnsmessage.Message) *dnsmessage.Option {
+	t.Helper()
+	if query == nil {
+		t.Fatal("no query received")
+	}
+
+	for _, res := range query.Additionals {
+		opt, ok := res.Body.(*dnsmessage.OPTResource)
+		if !ok {
+			continue
+		}
+		if res.Header.Class != udpPayloadSize {
+			t.Errorf("EDNS payload size %v, want %v", res.Header.Class, udpPayloadSize)
+		}
+		for _, option := range opt.Options {
+			if option.Code == optionCodeECS {
+				return &option
+			}
+		}
+		return nil
+	}
+
+	t.Fatal("no OPT record in query")
+	return nil
+}
+
+func TestDisableECS(t *testing.T) {
+	s := newStub(t, answer(addrs("192.0.2.1"), 300, 0), nil)
+
+	r := newDNSResolver(C.ResolverProtocolUDP, s.addr)
+	_, _, err := lookup(t, r, "ip4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if option := ecsOption(t, s.last.Load()); option != nil {
+		t.Errorf("ECS option %v is sent without disable_ecs", option)
+	}
+
+	r.disableECS = true
+	_, _, err = lookup(t, r, "ip4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	option := ecsOption(t, s.last.Load())
+	if option == nil {
+		t.Fatal("no ECS option with disable_ecs")
+	}
+	// family IPv4, source prefix 0, scope prefix 0
+	if !bytes.Equal(option.Data, []byte{0, 1, 0, 0}) {
+		t.Errorf("ECS option data %v, want [0 1 0 0]", option.Data)
+	}
+}
+
+func TestHTTPS(t *testing.T) {
+	want := addrs("192.0.2.1", "2001:db8::1")
+	var last atomic.Pointer[dnsmessage.Message]
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost || req.Header.Get("Content-Type") != "application/dns-message" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(req.Body)
+		var query dnsmessage.Message
+		if query.Unpack(body) != nil || query.ID != 0 {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		last.Store(&query)
+
+		msg := reply(query, want, 300, 0)
+		packed, _ := msg.Pack()
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(packed)
+	}))
+	defer srv.Close()
+
+	r := newDNSResolver(C.ResolverProtocolHTTPS, srv.URL+"/dns-query")
+	r.httpClient = srv.Client()
+	r.disableECS = true
+
+	got, ttl, err := lookup(t, r, "ip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if ttl != 300*time.Second {
+		t.Errorf("got TTL %v, want 5m", ttl)
+	}
+	if ecsOption(t, last.Load()) == nil {
+		t.Error("no ECS option over HTTPS with disable_ecs")
+	}
+}
+
+func TestHTTPSStatusError(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "nope", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	r := newDNSResolver(C.ResolverProtocolHTTPS, srv.URL)
+	r.httpClient = srv.Client()
+	_, _, err := lookup(t, r, "ip4")
+	if err == nil {
+		t.Error("HTTP 503 should fail")
+	}
+}
+
+func TestTTLMinimum(t *testing.T) {
+	s := newStub(t, func(query dnsmessage.Message) []dnsmessage.Message {
+		switch query.Questions[0].Type {
+		case dnsmessage.TypeA:
+			// the CNAME expires first
+			return []dnsmessage.Message{reply(query, addrs("192.0.2.1", "192.0.2.2"), 300, 120)}
+		default:
+			return []dnsmessage.Message{reply(query, addrs("2001:db8::1"), 60, 0)}
+		}
+	}, nil)
+	r := newDNSResolver(C.ResolverProtocolUDP, s.addr)
+
+	tests := []struct {
+		network string
+		want    time.Duration
+	}{
+		{"ip4", 120 * time.Second},
+		{"ip6", 60 * time.Second},
+		{"ip", 60 * time.Second},
+	}
+	for _, tt := range tests {
+		_, ttl, err := lookup(t, r, tt.network)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ttl != tt.want {
+			t.Errorf("%v: got TTL %v, want %v", tt.network, ttl, tt.want)
+		}
+	}
+}
+
+func TestNameError(t *testing.T) {
+	s := newStub(t, func(query dnsmessage.Message) []dnsmessage.Message {
+		msg := reply(query, nil, 0, 0)
+		msg.RCode = dnsmessage.RCodeNameError
+		return []dnsmessage.Message{msg}
+	}, nil)
+
+	_, _, err := lookup(t, newDNSResolver(C.ResolverProtocolUDP, s.addr), "ip")
+	if err == nil {
+		t.Error("NXDOMAIN should fail")
+	}
+}
+
+// A closed port, the connection is refused immediately.
+func closedAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+func TestServersInOrder(t *testing.T) {
+	want := addrs("192.0.2.1")
+	first := newStub(t, nil, answer(want, 300, 0))
+	second := newStub(t, nil, answer(addrs("198.51.100.1"), 300, 0))
+
+	got, _, err := lookup(t, newDNSResolver(C.ResolverProtocolTCP, first.addr, second.addr), "ip4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want the answer of the first server %v", got, want)
+	}
+	if n := second.queries.Load(); n != 0 {
+		t.Errorf("the second server got %v queries, want 0", n)
+	}
+
+	// the failed one is skipped
+	got, _, err = lookup(t, newDNSResolver(C.ResolverProtocolTCP, closedAddr(t), first.addr), "ip4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want the answer of the second server %v", got, want)
+	}
+
+	_, _, err = lookup(t, newDNSResolver(C.ResolverProtocolTCP, closedAddr(t), closedAddr(t)), "ip4")
+	if err == nil {
+		t.Error("all servers failed, but no error")
+	}
+}
+
+func TestContextDeadline(t *testing.T) {
+	// never reply
+	first := newStub(t, nil, nil)
+	second := newStub(t, nil, nil)
+
+	for _, protocol := range []string{C.ResolverProtocolUDP, C.ResolverProtocolTCP} {
+		t.Run(protocol, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+			_, _, err := newDNSResolver(protocol, first.addr, second.addr).Lookup(ctx, "ip", "example.com")
+			elapsed := time.Since(start)
+			if err == nil {
+				t.Fatal("no error after the deadline")
+			}
+			if elapsed > time.Second {
+				t.Errorf("took %v, the deadline isn't honored", elapsed)
+			}
+		})
+	}
+	// no time for the second server
+	if n := second.queries.Load(); n != 0 {
+		t.Errorf("the second server got %v queries after the deadline, want 0", n)
+	}
+}
+
+// A resolver that counts the calls and always answers the same.
+type fakeResolver struct {
+	calls atomic.Int32
+	addrs []netip.Addr
+	ttl   time.Duration
+	err   error
+}
+
+func (f *fakeResolver) Lookup(ctx context.Context, network string, host string) ([]netip.Addr, time.Duration, error) {
+	f.calls.Add(1)
+	return slices.Clone(f.addrs), f.ttl, f.err
+}
+
+func TestCachedResolver(t *testing.T) {
+	tests := []struct {
+		name      string
+		ttl       time.Duration
+		maxTTL    time.Duration
+		wantTTL   time.Duration
+		wantCalls int32
+	}{
+		{"TTL below the cap", 30 * time.Second, time.Minute, 30 * time.Second, 1},
+		{"capped by CacheMaxTTL", time.Hour, time.Minute, time.Minute, 1},
+		{"TTL 0 isn't cached", 0, time.Minute, 0, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &fakeResolver{addrs: addrs("192.0.2.1", "2001:db8::1"), ttl: tt.ttl}
+			r := newCachedResolver(next, tt.maxTTL, 0)
+
+			got, ttl, err := lookup(t, r, "ip")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ttl != tt.wantTTL {
+				t.Errorf("got TTL %v, want %v", ttl, tt.wantTTL)
+			}
+			// the caller may sort it, that shouldn't change the cache
+			slices.Reverse(got)
+
+			got, ttl, err = lookup(t, r, "ip")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, next.addrs) {
+				t.Errorf("got %v from cache, want %v", got, next.addrs)
+			}
+			if ttl > tt.wantTTL {
+				t.Errorf("got TTL %v from cache, want at most %v", ttl, tt.wantTTL)
+			}
+			if n := next.calls.Load(); n != tt.wantCalls {
+				t.Errorf("got %v lookups, want %v", n, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestCachedResolverKeys(t *testing.T) {
+	next := &fakeResolver{addrs: addrs("192.0.2.1"), ttl: time.Minute}
+	r := newCachedResolver(next, time.Minute, 0)
+
+	for _, network := range []string{"ip", "ip4", "ip", "ip4"} {
+		_, _, err := lookup(t, r, network)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// cached by network and host
+	if n := next.calls.Load(); n != 2 {
+		t.Errorf("got %v lookups, want 2", n)
+	}
+}
+
+func TestCachedResolverEviction(t *testing.T) {
+	ctx := context.Background()
+	next := &fakeResolver{addrs: addrs("192.0.2.1"), ttl: time.Minute}
+	r := newCachedResolver(next, time.Minute, 2)
+	t.Cleanup(r.cache.Close)
+
+	for _, host := range []string{"a.example", "b.example", "a.example", "c.example"} {
+		_, _, err := r.Lookup(ctx, "ip", host)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := r.cache.Len(); n != 2 {
+		t.Errorf("got %v cached hosts, want 2", n)
+	}
+
+	// b is the least recently used one
+	next.calls.Store(0)
+	for _, host := range []string{"a.example", "c.example", "b.example"} {
+		r.Lookup(ctx, "ip", host)
+	}
+	if n := next.calls.Load(); n != 1 {
+		t.Errorf("got %v lookups, want only the evicted host looked up again", n)
+	}
+}
+
+func TestCachedResolverSkipsFailure(t *testing.T) {
+	next := &fakeResolver{err: errors.New("server failure"), ttl: time.Minute}
+	r := newCachedResolver(next, time.Minute, 0)
+
+	for range 2 {
+		_, _, err := lookup(t, r, "ip")
+		if err == nil {
+			t.Fatal("want the error of the next resolver")
+		}
+	}
+	if n := next.calls.Load(); n != 2 {
+		t.Errorf("got %v lookups, failures shouldn't be cached", n)
+	}
+}
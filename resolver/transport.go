@@ -0,0 +1,212 @@
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	C "github.com/SourLemonJuice/ipapi-agent/constant"
+)
+
+const (
+	// the recommended EDNS buffer size of DNS Flag Day 2020
+	udpPayloadSize = 1232
+	optionCodeECS  = 8
+	// the biggest message over TCP and HTTPS
+	maxMessageSize = 65535
+)
+
+// Send the query to server with the protocol, and return the answer to it.
+func (r *dnsResolver) exchange(ctx context.Context, server string, query dnsmessage.Message) (dnsmessage.Message, error) {
+	switch r.protocol {
+	case C.ResolverProtocolUDP:
+		query.ID = rand.N[uint16](65535) + 1
+		answer, err := exchangeUDP(ctx, server, query)
+		if err == nil && answer.Truncated {
+			// too big for UDP, retry with TCP
+			return exchangeStream(ctx, server, query, nil)
+		}
+		return answer, err
+	case C.ResolverProtocolTCP:
+		query.ID = rand.N[uint16](65535) + 1
+		return exchangeStream(ctx, server, query, nil)
+	case C.ResolverProtocolTLS:
+		query.ID = rand.N[uint16](65535) + 1
+		host, _, err := net.SplitHostPort(server)
+		if err != nil {
+			return dnsmessage.Message{}, err
+		}
+		return exchangeStream(ctx, server, query, &tls.Config{ServerName: host})
+	case C.ResolverProtocolHTTPS:
+		// RFC 8484 section 4.1, ID should be 0 for the HTTP cache
+		query.ID = 0
+		return r.exchangeHTTPS(ctx, server, query)
+	default:
+		return dnsmessage.Message{}, fmt.Errorf("unknown protocol '%v'", r.protocol)
+	}
+}
+
+func exchangeUDP(ctx context.Context, server string, query dnsmessage.Message) (dnsmessage.Message, error) {
+	var answer dnsmessage.Message
+
+	packed, err := query.Pack()
+	if err != nil {
+		return answer, err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", server)
+	if err != nil {
+		return answer, err
+	}
+	defer conn.Close()
+	stop := closeOnDone(ctx, conn)
+	defer stop()
+
+	_, err = conn.Write(packed)
+	if err != nil {
+		return answer, err
+	}
+
+	buf := make([]byte, udpPayloadSize)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return answer, err
+		}
+
+		// ignore the mismatched or broken packets, they may be spoofed
+		err = answer.Unpack(buf[:n])
+		if err != nil || !isAnswerOf(answer, query) {
+			continue
+		}
+		return answer, nil
+	}
+}
+
+// Exchange over TCP, or TLS if tlsConf isn't nil. Messages are prefixed with a two bytes length.
+func exchangeStream(ctx context.Context, server string, query dnsmessage.Message, tlsConf *tls.Config) (dnsmessage.Message, error) {
+	var answer dnsmessage.Message
+
+	packed, err := query.Pack()
+	if err != nil {
+		return answer, err
+	}
+
+	var conn net.Conn
+	if tlsConf == nil {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", server)
+	} else {
+		dialer := tls.Dialer{Config: tlsConf}
+		conn, err = dialer.DialContext(ctx, "tcp", server)
+	}
+	if err != nil {
+		return answer, err
+	}
+	defer conn.Close()
+	stop := closeOnDone(ctx, conn)
+	defer stop()
+
+	_, err = conn.Write(binary.BigEndian.AppendUint16(nil, uint16(len(packed))))
+	if err != nil {
+		return answer, err
+	}
+	_, err = conn.Write(packed)
+	if err != nil {
+		return answer, err
+	}
+
+	var length uint16
+	err = binary.Read(conn, binary.BigEndian, &length)
+	if err != nil {
+		return answer, err
+	}
+	buf := make([]byte, length)
+	_, err = io.ReadFull(conn, buf)
+	if err != nil {
+		return answer, err
+	}
+
+	err = answer.Unpack(buf)
+	if err != nil {
+		return answer, err
+	}
+	if !isAnswerOf(answer, query) {
+		return answer, errors.New("mismatched answer")
+	}
+	return answer, nil
+}
+
+// DNS-over-HTTPS with POST method.
+func (r *dnsResolver) exchangeHTTPS(ctx context.Context, server string, query dnsmessage.Message) (dnsmessage.Message, error) {
+	var answer dnsmessage.Message
+
+	packed, err := query.Pack()
+	if err != nil {
+		return answer, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server, bytes.NewReader(packed))
+	if err != nil {
+		return answer, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return answer, fmt.Errorf("HTTP request error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return answer, fmt.Errorf("HTTP status code %v", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxMessageSize))
+	if err != nil {
+		return answer, err
+	}
+
+	err = answer.Unpack(body)
+	if err != nil {
+		return answer, err
+	}
+	if !isAnswerOf(answer, query) {
+		return answer, errors.New("mismatched answer")
+	}
+	return answer, nil
+}
+
+// Interrupt the blocking I/O of conn when ctx is done, call the returned function to stop watching.
+func closeOnDone(ctx context.Context, conn net.Conn) func() bool {
+	return context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+}
+
+func isAnswerOf(answer dnsmessage.Message, query dnsmessage.Message) bool {
+	if !answer.Response || answer.ID != query.ID || len(answer.Questions) != 1 {
+		return false
+	}
+
+	q := query.Questions[0]
+	a := answer.Questions[0]
+	return a.Type == q.Type && a.Class == q.Class && equalFoldName(a.Name, q.Name)
+}
+
+// DNS names are case-insensitive, some servers randomize the case(0x20 encoding).
+func equalFoldName(a dnsmessage.Name, b dnsmessage.Name) bool {
+	return bytes.EqualFold(a.Data[:a.Length], b.Data[:b.Length])
+}
@@ -1,16 +1,89 @@
 package response
 
+import "encoding/xml"
+
 type Query struct {
-	Status      string `json:"status"`
-	Message     string `json:"message,omitempty"`
-	DataSource  string `json:"dataSource"`
-	Country     string `json:"country"`
-	CountryCode string `json:"countryCode"`
-	Region      string `json:"region"`
-	Timezone    string `json:"timezone"`
-	UTCOffset   int    `json:"utcOffset"`
-	Org         string `json:"org"`
-	ISP         string `json:"isp"` // when no ISP data available, set to empty string
-	ASN         string `json:"asn"`
-	Anycast     bool   `json:"anycast,omitempty"` // only ipinfo-free can provided anycast info
+	XMLName        xml.Name  `json:"-" xml:"query"`
+	Status         string    `json:"status" xml:"status"`
+	Message        string    `json:"message,omitempty" xml:"message,omitempty"`
+	Query          string    `json:"query" xml:"query"`                               // the normalized query input, IP address or domain in Unicode form
+	QueryASCII     string    `json:"queryASCII,omitempty" xml:"queryASCII,omitempty"` // ASCII form(punycode) of domain
+	DataSource     string    `json:"dataSource" xml:"dataSource"`
+	Hostname       string    `json:"hostname,omitempty" xml:"hostname,omitempty"` // reverse DNS hostname
+	Family         string    `json:"family" xml:"family"`                         // "IPv4" or "IPv6"
+	Country        string    `json:"country" xml:"country"`
+	CountryCode    string    `json:"countryCode" xml:"countryCode"`
+	CountryAlpha3  string    `json:"countryAlpha3,omitempty" xml:"countryAlpha3,omitempty"`
+	CountryNumeric string    `json:"countryNumeric,omitempty" xml:"countryNumeric,omitempty"`
+	CountryFlag    string    `json:"countryFlag,omitempty" xml:"countryFlag,omitempty"`
+	Capital        string    `json:"capital,omitempty" xml:"capital,omitempty"`
+	CallingCode    string    `json:"callingCode,omitempty" xml:"callingCode,omitempty"`
+	Currency       string    `json:"currency,omitempty" xml:"currency,omitempty"`
+	TLD            string    `json:"tld,omitempty" xml:"tld,omitempty"`
+	Subregion      string    `json:"subregion,omitempty" xml:"subregion,omitempty"`
+	Region         string    `json:"region" xml:"region"`
+	RegionCode     string    `json:"regionCode,omitempty" xml:"regionCode,omitempty"` // ISO 3166-2 code
+	Timezone       string    `json:"timezone" xml:"timezone"`
+	UTCOffset      int       `json:"utcOffset" xml:"utcOffset"`
+	IsDST          bool      `json:"isDST" xml:"isDST"`
+	LocalTime      string    `json:"localTime" xml:"localTime"`
+	Org            string    `json:"org" xml:"org"`
+	ISP            string    `json:"isp" xml:"isp"` // when no ISP data available, set to empty string
+	ASN            string    `json:"asn" xml:"asn"`
+	AS             AS        `json:"as" xml:"as"`
+	Anycast        bool      `json:"anycast,omitempty" xml:"anycast,omitempty"` // only ipinfo-free can provided anycast info
+	Privacy        Privacy   `json:"privacy" xml:"privacy"`
+	Reserved       *Reserved `json:"reserved,omitempty" xml:"reserved,omitempty"` // only for special-purpose address in the reserved mode
+}
+
+// Autonomous System details
+type AS struct {
+	Number uint32 `json:"number" xml:"number"`
+	Name   string `json:"name" xml:"name"`
+	Route  string `json:"route,omitempty" xml:"route,omitempty"` // the announced network prefix, like "1.1.1.0/24"
+}
+
+// Special-purpose address details, from the IANA registries
+type Reserved struct {
+	Name        string   `json:"name" xml:"name"` // entry name of the registry
+	Description string   `json:"description" xml:"description"`
+	Prefix      string   `json:"prefix" xml:"prefix"`
+	RFC         []string `json:"rfc" xml:"rfc"`
+}
+
+// Privacy and threat flags, merged from upstream and the local lists
+type Privacy struct {
+	Proxy   bool `json:"proxy" xml:"proxy"`
+	VPN     bool `json:"vpn" xml:"vpn"`
+	Tor     bool `json:"tor" xml:"tor"`
+	Hosting bool `json:"hosting" xml:"hosting"`
+	Mobile  bool `json:"mobile" xml:"mobile"`
+}
+
+// Return true if any flag is set.
+func (privacy Privacy) Any() bool {
+	return privacy.Proxy || privacy.VPN || privacy.Tor || privacy.Hosting || privacy.Mobile
+}
+
+type Failure struct {
+	XMLName xml.Name `json:"-" xml:"query"`
+	Status  string   `json:"status" xml:"status"`
+	Message string   `json:"message" xml:"message"`
+}
+
+// All the resolved addresses of a domain
+type Domain struct {
+	XMLName     xml.Name     `json:"-" xml:"domain"`
+	Status      string       `json:"status" xml:"status"`
+	Domain      string       `json:"domain" xml:"name"` // Unicode form
+	DomainASCII string       `json:"domainASCII" xml:"nameASCII"`
+	Addresses   []DomainAddr `json:"addresses" xml:"addresses>address"`
+}
+
+type DomainAddr struct {
+	Addr    string `json:"addr" xml:"addr"`
+	Family  string `json:"family" xml:"family"` // "IPv4" or "IPv6"
+	Status  string `json:"status" xml:"status"` // success, failure, or rejected
+	Message string `json:"message,omitempty" xml:"message,omitempty"`
+	Query   *Query `json:"query,omitempty" xml:"query,omitempty"` // only when success
 }
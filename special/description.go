@@ -0,0 +1,60 @@
+package special
+
+// Human-friendly descriptions of the entries, keyed by address block.
+// The registry name is used if not found.
+var descriptions = map[string]string{
+	// IPv4
+	"0.0.0.0/8":          "\"This network\", only used as source address",
+	"0.0.0.0/32":         "IPv4 unspecified address",
+	"10.0.0.0/8":         "RFC1918 private network",
+	"100.64.0.0/10":      "CGNAT shared space",
+	"127.0.0.0/8":        "IPv4 loopback",
+	"169.254.0.0/16":     "IPv4 link-local",
+	"172.16.0.0/12":      "RFC1918 private network",
+	"192.0.0.0/24":       "IETF protocol assignments",
+	"192.0.0.0/29":       "DS-Lite IPv4 service continuity",
+	"192.0.0.8/32":       "IPv4 dummy address for traceroute",
+	"192.0.0.9/32":       "PCP anycast",
+	"192.0.0.10/32":      "TURN anycast",
+	"192.0.0.170/32":     "NAT64/DNS64 discovery",
+	"192.0.0.171/32":     "NAT64/DNS64 discovery",
+	"192.0.2.0/24":       "Documentation example network",
+	"192.31.196.0/24":    "AS112 DNS sink",
+	"192.52.193.0/24":    "Automatic multicast tunneling",
+	"192.88.99.0/24":     "Deprecated 6to4 relay anycast",
+	"192.168.0.0/16":     "RFC1918 private network",
+	"192.175.48.0/24":    "AS112 DNS sink, direct delegation",
+	"198.18.0.0/15":      "Network benchmark testing",
+	"198.51.100.0/24":    "Documentation example network",
+	"203.0.113.0/24":     "Documentation example network",
+	"224.0.0.0/4":        "IPv4 multicast",
+	"240.0.0.0/4":        "Reserved for future use, the former class E",
+	"255.255.255.255/32": "IPv4 limited broadcast",
+
+	// IPv6
+	"::1/128":           "IPv6 loopback",
+	"::/128":            "IPv6 unspecified address",
+	"::ffff:0.0.0.0/96": "IPv4-mapped IPv6 address",
+	"64:ff9b::/96":      "NAT64 well-known prefix",
+	"64:ff9b:1::/48":    "NAT64 local-use prefix",
+	"100::/64":          "Discard-only, for black hole routing",
+	"2001::/23":         "IETF protocol assignments",
+	"2001::/32":         "Teredo tunneling",
+	"2001:1::1/128":     "PCP anycast",
+	"2001:1::2/128":     "TURN anycast",
+	"2001:1::3/128":     "DNS-SD service registration anycast",
+	"2001:2::/48":       "Network benchmark testing",
+	"2001:3::/32":       "Automatic multicast tunneling",
+	"2001:4:112::/48":   "AS112 DNS sink",
+	"2001:10::/28":      "Deprecated ORCHID",
+	"2001:20::/28":      "ORCHIDv2 cryptographic hash identifiers",
+	"2001:30::/28":      "Drone remote ID entity tags",
+	"2001:db8::/32":     "IPv6 documentation example network",
+	"2002::/16":         "6to4 tunneling",
+	"2620:4f:8000::/48": "AS112 DNS sink, direct delegation",
+	"3fff::/20":         "IPv6 documentation example network",
+	"5f00::/16":         "SRv6 segment identifiers",
+	"fc00::/7":          "IPv6 unique local address, private network",
+	"fe80::/10":         "IPv6 link-local",
+	"ff00::/8":          "IPv6 multicast",
+}
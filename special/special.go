@@ -0,0 +1,211 @@
+package special
+
+import (
+	"embed"
+	"encoding/csv"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strings"
+
+	"github.com/SourLemonJuice/ipapi-agent/config"
+	"github.com/SourLemonJuice/ipapi-agent/debug"
+)
+
+// Snapshots of the IANA special-purpose address registries, update them by replacing the CSV files:
+// https://www.iana.org/assignments/iana-ipv4-special-registry/
+// https://www.iana.org/assignments/iana-ipv6-special-registry/
+//
+//go:embed data/*.csv
+var dataFS embed.FS
+
+// One address block of the registries.
+type Entry struct {
+	Prefix      netip.Prefix
+	Name        string
+	Description string   // a human-friendly one, like "CGNAT shared space"
+	RFC         []string // like "RFC1918"
+	// "Globally Reachable" column of the registry, false if "N/A" or empty(deprecated entries)
+	GloballyReachable bool
+}
+
+// Not a part of the special-purpose registries, but they are not unicast.
+var extraEntries = []Entry{
+	{Prefix: netip.MustParsePrefix("224.0.0.0/4"), Name: "Multicast", RFC: []string{"RFC5771"}},
+	{Prefix: netip.MustParsePrefix("ff00::/8"), Name: "Multicast", RFC: []string{"RFC4291"}},
+}
+
+// Translation prefixes embed another address, they are rejected by default even if the registry
+// says globally reachable, the embedded one should be queried instead.
+var translationPrefixes = map[netip.Prefix]bool{
+	netip.MustParsePrefix("64:ff9b::/96"):   true, // NAT64 well-known prefix
+	netip.MustParsePrefix("64:ff9b:1::/48"): true, // local-use NAT64
+	netip.MustParsePrefix("2002::/16"):      true, // 6to4
+	netip.MustParsePrefix("2001::/32"):      true, // Teredo
+}
+
+var (
+	registry []Entry
+	// entries whose default policy is overridden by config, keyed by prefix
+	allowed = make(map[netip.Prefix]bool)
+	denied  = make(map[netip.Prefix]bool)
+)
+
+func init() {
+	for _, name := range []string{"data/iana-ipv4-special-registry.csv", "data/iana-ipv6-special-registry.csv"} {
+		entries, err := parseRegistry(name)
+		if err != nil {
+			panic(err)
+		}
+		registry = append(registry, entries...)
+	}
+	registry = append(registry, extraEntries...)
+
+	for i, entry := range registry {
+		desc, ok := descriptions[entry.Prefix.String()]
+		if !ok {
+			desc = entry.Name
+		}
+		registry[i].Description = desc
+	}
+}
+
+var (
+	footnoteRegexp = regexp.MustCompile(`\s*\[\d+\]`)
+	rfcRegexp      = regexp.MustCompile(`RFC\d+`)
+)
+
+func parseRegistry(name string) ([]Entry, error) {
+	file, err := dataFS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("can't parse %v: %w", name, err)
+	}
+
+	var entries []Entry
+	// skip the header row
+	for _, record := range records[1:] {
+		if len(record) < 9 {
+			return nil, fmt.Errorf("bad row in %v: %v", name, record)
+		}
+
+		reachable := strings.HasPrefix(record[8], "True")
+		// a row may have multiple blocks, like "192.0.0.170/32, 192.0.0.171/32"
+		for _, block := range strings.Split(footnoteRegexp.ReplaceAllString(record[0], ""), ",") {
+			prefix, err := netip.ParsePrefix(strings.TrimSpace(block))
+			if err != nil {
+				return nil, fmt.Errorf("bad address block in %v: %w", name, err)
+			}
+
+			entries = append(entries, Entry{
+				Prefix:            prefix,
+				Name:              strings.Trim(record[1], `"`),
+				RFC:               rfcRegexp.FindAllString(record[2], -1),
+				GloballyReachable: reachable,
+			})
+		}
+	}
+
+	return entries, nil
+}
+
+// Apply the allow and deny lists of config, every item should be an address block of the registries.
+func Load(conf config.ConfigSpecial) error {
+	clear(allowed)
+	clear(denied)
+
+	for _, block := range conf.Allow {
+		prefix, err := findBlock(block)
+		if err != nil {
+			return fmt.Errorf("special.allow: %w", err)
+		}
+		allowed[prefix] = true
+	}
+
+	for _, block := range conf.Deny {
+		prefix, err := findBlock(block)
+		if err != nil {
+			return fmt.Errorf("special.deny: %w", err)
+		}
+		if allowed[prefix] {
+			return fmt.Errorf("%v is both allowed and denied", prefix)
+		}
+		denied[prefix] = true
+	}
+
+	debug.Logger.Printf("Special-purpose registry loaded, entries: %v, allowed: %v, denied: %v", len(registry), len(allowed), len(denied))
+	return nil
+}
+
+func findBlock(block string) (netip.Prefix, error) {
+	prefix, err := netip.ParsePrefix(block)
+	if err != nil {
+		return prefix, err
+	}
+
+	for _, entry := range registry {
+		if entry.Prefix == prefix {
+			return prefix, nil
+		}
+	}
+	return prefix, fmt.Errorf("%v is not in the registry", block)
+}
+
+// Return the most specific entry that contains addr.
+func Lookup(addr netip.Addr) (Entry, bool) {
+	addr = addr.WithZone("")
+
+	var found Entry
+	ok := false
+	for _, entry := range registry {
+		if !entry.Prefix.Contains(addr) {
+			continue
+		}
+		if !ok || entry.Prefix.Bits() > found.Prefix.Bits() {
+			found = entry
+			ok = true
+		}
+	}
+
+	return found, ok
+}
+
+// The matched entry of a rejected address.
+type RejectError struct {
+	Entry Entry
+}
+
+func (e *RejectError) Error() string {
+	return fmt.Sprintf("special-purpose address: %v (%v)", e.Entry.Name, e.Entry.Prefix)
+}
+
+// Return *RejectError if addr is in a special-purpose block that isn't globally reachable, or a translation prefix.
+// The config lists take precedence, a listed block also covers the blocks inside it, and the most specific listed one wins.
+func Check(addr netip.Addr) error {
+	entry, ok := Lookup(addr)
+	if !ok {
+		return nil
+	}
+
+	reject := !entry.GloballyReachable || translationPrefixes[entry.Prefix]
+	bits := -1
+	for _, e := range registry {
+		if !allowed[e.Prefix] && !denied[e.Prefix] {
+			continue
+		}
+		if e.Prefix.Bits() > bits && e.Prefix.Contains(addr.WithZone("")) {
+			bits = e.Prefix.Bits()
+			reject = denied[e.Prefix]
+		}
+	}
+
+	if reject {
+		return &RejectError{Entry: entry}
+	}
+	return nil
+}
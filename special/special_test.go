@@ -0,0 +1,127 @@
+package special
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/SourLemonJuice/ipapi-agent/config"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		addr   string
+		prefix string // empty if not found
+		name   string
+	}{
+		{"10.1.2.3", "10.0.0.0/8", "Private-Use"},
+		{"100.64.0.1", "100.64.0.0/10", "Shared Address Space"},
+		{"0.0.0.0", "0.0.0.0/32", "This host on this network"},
+		{"0.1.2.3", "0.0.0.0/8", "This network"},
+		// the most specific one in 192.0.0.0/24
+		{"192.0.0.9", "192.0.0.9/32", "Port Control Protocol Anycast"},
+		{"192.0.0.3", "192.0.0.0/29", "IPv4 Service Continuity Prefix"},
+		{"192.0.0.100", "192.0.0.0/24", "IETF Protocol Assignments"},
+		// one row with two blocks
+		{"192.0.0.171", "192.0.0.171/32", "NAT64/DNS64 Discovery"},
+		{"239.1.2.3", "224.0.0.0/4", "Multicast"},
+		{"::1", "::1/128", "Loopback Address"},
+		{"2001:db8::1", "2001:db8::/32", "Documentation"},
+		{"2001:0:1::1", "2001::/32", "TEREDO"},
+		{"2001:1ff::1", "2001::/23", "IETF Protocol Assignments"},
+		{"64:ff9b::808:808", "64:ff9b::/96", "IPv4-IPv6 Translat."},
+		{"fe80::1%eth0", "fe80::/10", "Link-Local Unicast"},
+		{"ff02::1", "ff00::/8", "Multicast"},
+		{"8.8.8.8", "", ""},
+		{"2606:4700::1111", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			entry, ok := Lookup(netip.MustParseAddr(tt.addr))
+			if ok != (tt.prefix != "") {
+				t.Fatalf("Lookup(%v) found %v, want %q", tt.addr, ok, tt.prefix)
+			}
+			if !ok {
+				return
+			}
+			if entry.Prefix.String() != tt.prefix || entry.Name != tt.name {
+				t.Errorf("Lookup(%v) = %v %q, want %v %q", tt.addr, entry.Prefix, entry.Name, tt.prefix, tt.name)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	t.Cleanup(func() {
+		Load(config.DefaultSpecial)
+	})
+
+	tests := []struct {
+		name   string
+		allow  []string
+		deny   []string
+		addr   string
+		reject bool
+	}{
+		{"public", nil, nil, "8.8.8.8", false},
+		{"private", nil, nil, "192.168.1.1", true},
+		{"multicast", nil, nil, "224.0.0.251", true},
+		{"globally reachable", nil, nil, "192.0.0.9", false},
+		{"not reachable inside a reachable parent", nil, nil, "192.0.0.8", true},
+		{"NAT64 by default", nil, nil, "64:ff9b::808:808", true},
+		{"local NAT64 by default", nil, nil, "64:ff9b:1::1", true},
+		{"6to4 by default", nil, nil, "2002:808:808::1", true},
+		{"Teredo by default", nil, nil, "2001:0:4136:e378::1", true},
+		{"allowed NAT64", []string{"64:ff9b::/96"}, nil, "64:ff9b::808:808", false},
+		{"allowed", []string{"100.64.0.0/10"}, nil, "100.64.1.1", false},
+		{"allow is per block", []string{"100.64.0.0/10"}, nil, "10.0.0.1", true},
+		{"denied globally reachable", nil, []string{"192.31.196.0/24"}, "192.31.196.1", true},
+		// a listed block covers the ones inside it
+		{"deny on a broad block", nil, []string{"192.0.0.0/24"}, "192.0.0.9", true},
+		{"allow on a broad block", []string{"2001::/23"}, nil, "2001:2::1", false},
+		// the most specific listed block wins
+		{"specific allow in a denied block", []string{"192.0.0.9/32"}, []string{"192.0.0.0/24"}, "192.0.0.9", false},
+		{"specific allow doesn't cover siblings", []string{"192.0.0.9/32"}, []string{"192.0.0.0/24"}, "192.0.0.10", true},
+		{"specific deny in an allowed block", []string{"2001::/23"}, []string{"2001:db8::/32"}, "2001:db8::1", true},
+		{"unlisted inside a listed block", []string{"2001::/23"}, []string{"2001:db8::/32"}, "2001:2::1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Load(config.ConfigSpecial{Allow: tt.allow, Deny: tt.deny})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = Check(netip.MustParseAddr(tt.addr))
+			if (err != nil) != tt.reject {
+				t.Fatalf("Check(%v) = %v, want reject %v", tt.addr, err, tt.reject)
+			}
+
+			var rejectErr *RejectError
+			if err != nil && !errors.As(err, &rejectErr) {
+				t.Errorf("Check(%v) = %T, want *RejectError", tt.addr, err)
+			}
+		})
+	}
+}
+
+func TestLoadError(t *testing.T) {
+	t.Cleanup(func() {
+		Load(config.DefaultSpecial)
+	})
+
+	tests := []config.ConfigSpecial{
+		{Allow: []string{"10.0.0.0/9"}},
+		{Deny: []string{"8.8.8.0/24"}},
+		{Allow: []string{"bad"}},
+		{Allow: []string{"10.0.0.0/8"}, Deny: []string{"10.0.0.0/8"}},
+	}
+
+	for _, conf := range tests {
+		if Load(conf) == nil {
+			t.Errorf("Load(%+v) should fail", conf)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+
+	"github.com/SourLemonJuice/ipapi-agent/config"
+	C "github.com/SourLemonJuice/ipapi-agent/constant"
+	"github.com/SourLemonJuice/ipapi-agent/response"
+)
+
+// User-defined text output templates, nil means using the built-in layout.
+var (
+	textTemplate        *template.Template
+	textFailureTemplate *template.Template
+)
+
+// Data of the success text template, all the Query fields can be used directly, like {{.Country}}.
+type textQueryData struct {
+	templateQuery
+	Addr     string
+	ASCII    bool // the client wants ASCII only output
+	colorful bool
+}
+
+// Embedded with another name, or {{.Query}} would be the whole struct instead of the query field.
+type templateQuery = response.Query
+
+// Data of the failure text template.
+type textFailureData struct {
+	Message  string
+	ASCII    bool
+	colorful bool
+}
+
+var textColors = map[string]color.Attribute{
+	"red":     color.FgHiRed,
+	"green":   color.FgHiGreen,
+	"yellow":  color.FgHiYellow,
+	"blue":    color.FgHiBlue,
+	"magenta": color.FgHiMagenta,
+	"cyan":    color.FgHiCyan,
+	"white":   color.FgHiWhite,
+	"bold":    color.Bold,
+}
+
+var textTemplateFuncs = template.FuncMap{
+	"utcOffsetToISO8601": utcOffsetToISO8601,
+	"privacyFlags":       privacyFlags,
+	"padLeft":            padLeft,
+	"padRight":           padRight,
+}
+
+// Colorize the text like {{.Color "green" "●"}}, only when the client supports color.
+func (data textQueryData) Color(name string, text string) (string, error) {
+	return colorize(data.colorful, name, text)
+}
+
+func (data textFailureData) Color(name string, text string) (string, error) {
+	return colorize(data.colorful, name, text)
+}
+
+func colorize(colorful bool, name string, text string) (string, error) {
+	attr, ok := textColors[name]
+	if !ok {
+		return "", fmt.Errorf("unknown color '%v'", name)
+	}
+
+	c := color.New(attr)
+	if !colorful {
+		c.DisableColor()
+	}
+	return c.Sprint(text), nil
+}
+
+// Pad spaces to the left until the text has width characters.
+func padLeft(width int, text string) string {
+	n := width - utf8.RuneCountInString(text)
+	if n <= 0 {
+		return text
+	}
+	return strings.Repeat(" ", n) + text
+}
+
+// Pad spaces to the right until the text has width characters.
+func padRight(width int, text string) string {
+	n := width - utf8.RuneCountInString(text)
+	if n <= 0 {
+		return text
+	}
+	return text + strings.Repeat(" ", n)
+}
+
+// Parse the templates in config, and try them with sample data, so a broken template fails fast.
+func loadTextTemplates(conf config.ConfigText) error {
+	var err error
+
+	textTemplate, err = parseTextTemplate("text.template", conf.Template, conf.TemplateFile)
+	if err != nil {
+		return err
+	}
+	if textTemplate != nil {
+		// a normal response, and one of the reserved mode which has .Reserved but no location
+		samples := []textQueryData{
+			{
+				templateQuery: response.Query{Status: C.ResponseStatusSuccess, Query: "192.0.2.1", Hostname: "host.example", Timezone: "UTC"},
+				Addr:          "192.0.2.1",
+			},
+			{
+				templateQuery: response.Query{
+					Status:     C.ResponseStatusSuccess,
+					Query:      "10.0.0.1",
+					DataSource: "iana",
+					Reserved: &response.Reserved{
+						Name:        "Private-Use",
+						Description: "Private network",
+						Prefix:      "10.0.0.0/8",
+						RFC:         []string{"RFC1918"},
+					},
+				},
+				Addr: "10.0.0.1",
+			},
+		}
+		for _, sample := range samples {
+			err = textTemplate.Execute(io.Discard, sample)
+			if err != nil {
+				return fmt.Errorf("text.template check failure with %v: %w", sample.Addr, err)
+			}
+		}
+	}
+
+	textFailureTemplate, err = parseTextTemplate("text.failure_template", conf.FailureTemplate, conf.FailureTemplateFile)
+	if err != nil {
+		return err
+	}
+	if textFailureTemplate != nil {
+		err = textFailureTemplate.Execute(io.Discard, textFailureData{Message: "sample"})
+		if err != nil {
+			return fmt.Errorf("text.failure_template check failure: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Return nil if neither the inline template nor the file is set.
+func parseTextTemplate(name string, inline string, path string) (*template.Template, error) {
+	if len(path) > 0 {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("can't read %v file: %w", name, err)
+		}
+		inline = string(content)
+	}
+
+	if len(inline) == 0 {
+		return nil, nil
+	}
+
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(textTemplateFuncs).Parse(inline)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse %v: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// Render the success text output, use the user-defined template if there is one.
+func textQuery(out output, addrStr string, resp response.Query) (string, error) {
+	if textTemplate == nil {
+		return respTXT(out, addrStr, resp), nil
+	}
+
+	var txt strings.Builder
+	err := textTemplate.Execute(&txt, textQueryData{
+		templateQuery: resp,
+		Addr:          addrStr,
+		ASCII:         out.ascii,
+		colorful:      out.colorful,
+	})
+	if err != nil {
+		return "", fmt.Errorf("text template error: %w", err)
+	}
+	return txt.String(), nil
+}
+
+// Render the failure text output, use the user-defined template if there is one.
+func textFailure(out output, message string) string {
+	if textFailureTemplate == nil {
+		return respTXTFailure(out, "%v", message)
+	}
+
+	var txt strings.Builder
+	err := textFailureTemplate.Execute(&txt, textFailureData{
+		Message:  message,
+		ASCII:    out.ascii,
+		colorful: out.colorful,
+	})
+	if err != nil {
+		// the template is checked at startup, this should not happen
+		return respTXTFailure(out, "%v", message)
+	}
+	return txt.String()
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/SourLemonJuice/ipapi-agent/config"
+	C "github.com/SourLemonJuice/ipapi-agent/constant"
+	"github.com/SourLemonJuice/ipapi-agent/response"
+)
+
+func TestTextTemplateFields(t *testing.T) {
+	t.Cleanup(func() {
+		textTemplate, textFailureTemplate = nil, nil
+	})
+
+	resp := response.Query{
+		Status:      C.ResponseStatusSuccess,
+		Query:       "bücher.example",
+		QueryASCII:  "xn--bcher-kva.example",
+		Country:     "Japan",
+		CountryCode: "JP",
+		AS:          response.AS{Number: 13335},
+	}
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		// the query field, not the whole response
+		{"{{.Query}}", "bücher.example"},
+		{"{{.QueryASCII}} {{.Addr}}", "xn--bcher-kva.example 192.0.2.1"},
+		{"{{.Country}}({{.CountryCode}}) AS{{.AS.Number}}", "Japan(JP) AS13335"},
+		{"{{if .Reserved}}reserved{{else}}{{.Status}}{{end}}", C.ResponseStatusSuccess},
+		{`{{.Color "green" .Query}}`, "bücher.example"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			err := loadTextTemplates(config.ConfigText{Template: tt.template})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := textQuery(output{format: formatText}, "192.0.2.1", resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTextTemplateCheck(t *testing.T) {
+	t.Cleanup(func() {
+		textTemplate, textFailureTemplate = nil, nil
+	})
+
+	tests := []struct {
+		name    string
+		conf    config.ConfigText
+		wantErr bool
+	}{
+		{"no template", config.ConfigText{}, false},
+		{"unknown field", config.ConfigText{Template: "{{.Nope}}"}, true},
+		{"unknown color", config.ConfigText{Template: `{{.Color "pink" "x"}}`}, true},
+		// .Reserved is nil in the normal sample
+		{"reserved only", config.ConfigText{Template: "{{.Reserved.Name}}"}, true},
+		{"bad syntax", config.ConfigText{Template: "{{.Query"}, true},
+		{"failure template", config.ConfigText{FailureTemplate: "{{.Message}}"}, false},
+		{"bad failure template", config.ConfigText{FailureTemplate: "{{.Query}}"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := loadTextTemplates(tt.conf)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("loadTextTemplates() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
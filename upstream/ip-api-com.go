@@ -11,17 +11,22 @@ import (
 
 /*
 Docs: https://ip-api.com/docs/api:json
-Example: http://ip-api.com/json/1.1.1.1?fields=53003
+Example: http://ip-api.com/json/1.1.1.1?fields=21221135
 
 	{
 	  "status": "success",
 	  "country": "Australia",
 	  "countryCode": "AU",
+	  "region": "QLD",
 	  "regionName": "Queensland",
 	  "timezone": "Australia/Brisbane",
 	  "isp": "Cloudflare, Inc",
 	  "org": "APNIC and Cloudflare DNS Resolver project",
-	  "as": "AS13335 Cloudflare, Inc."
+	  "as": "AS13335 Cloudflare, Inc.",
+	  "asname": "CLOUDFLARENET",
+	  "mobile": false,
+	  "proxy": false,
+	  "hosting": true
 	}
 */
 type ipApiCom struct {
@@ -29,15 +34,20 @@ type ipApiCom struct {
 	Message     string `json:"message"`
 	Country     string `json:"country"`
 	CountryCode string `json:"countryCode"`
+	Region      string `json:"region"`
 	RegionName  string `json:"regionName"`
 	Timezone    string `json:"timezone"`
 	ISP         string `json:"isp"`
 	Org         string `json:"org"`
 	AS          string `json:"as"`
+	ASName      string `json:"asname"`
+	Mobile      bool   `json:"mobile"`
+	Proxy       bool   `json:"proxy"`
+	Hosting     bool   `json:"hosting"`
 }
 
 func (data *ipApiCom) Fetch(ctx context.Context, addr string) (resp response.Query, err error) {
-	err = fetchJSON(ctx, fmt.Sprintf("http://ip-api.com/json/%v?fields=53003", addr), data)
+	err = fetchJSON(ctx, fmt.Sprintf("http://ip-api.com/json/%v?fields=21221135", addr), data)
 	if err != nil {
 		return resp, err
 	}
@@ -55,11 +65,12 @@ func (data *ipApiCom) Fetch(ctx context.Context, addr string) (resp response.Que
 	resp.Country = data.Country
 	resp.CountryCode = data.CountryCode
 	resp.Region = data.RegionName
+	resp.RegionCode = data.CountryCode + "-" + data.Region
 	resp.Timezone = data.Timezone
 
-	resp.UTCOffset, err = timezoneToUTCOffset(data.Timezone)
+	err = checkTimezone(data.Timezone)
 	if err != nil {
-		return resp, fmt.Errorf("can not convert UTC offset: %w", err)
+		return resp, err
 	}
 
 	resp.Org = data.Org
@@ -68,6 +79,15 @@ func (data *ipApiCom) Fetch(ctx context.Context, addr string) (resp response.Que
 	if err != nil {
 		return resp, fmt.Errorf("can not convert ASN: %w", err)
 	}
+	resp.AS.Number, err = ASNumber(resp.ASN)
+	if err != nil {
+		return resp, fmt.Errorf("can not convert ASN: %w", err)
+	}
+	resp.AS.Name = data.ASName
+
+	resp.Privacy.Proxy = data.Proxy
+	resp.Privacy.Hosting = data.Hosting
+	resp.Privacy.Mobile = data.Mobile
 
 	return resp, nil
 }
@@ -42,7 +42,9 @@ Example: https://ipapi.co/1.1.1.1/json/
 	}
 */
 type ipapiCo struct {
+	Network     string `json:"network"`
 	Region      string `json:"region"`
+	RegionCode  string `json:"region_code"`
 	CountryCode string `json:"country_code"`
 	CountryName string `json:"country_name"`
 	Timezone    string `json:"timezone"`
@@ -60,16 +62,26 @@ func (data *ipapiCo) Fetch(ctx context.Context, addr string) (resp response.Quer
 	resp.Country = data.CountryName
 	resp.CountryCode = data.CountryCode
 	resp.Region = data.Region
+	resp.RegionCode = data.CountryCode + "-" + data.RegionCode
 	resp.Timezone = data.Timezone
 
-	resp.UTCOffset, err = timezoneToUTCOffset(data.Timezone)
+	err = checkTimezone(data.Timezone)
 	if err != nil {
-		return resp, fmt.Errorf("can not convert UTC offset: %w", err)
+		return resp, err
 	}
 
 	resp.Org = data.Org
 	resp.ISP = resp.Org
-	resp.ASN = data.ASN
+	// some addresses have no ASN, leave the AS empty then
+	if data.ASN != "" {
+		resp.ASN = data.ASN
+		resp.AS.Number, err = ASNumber(data.ASN)
+		if err != nil {
+			return resp, fmt.Errorf("can not convert ASN: %w", err)
+		}
+		resp.AS.Name = data.Org
+		resp.AS.Route = data.Network
+	}
 
 	return resp, nil
 }
@@ -7,7 +7,6 @@ import (
 	"strings"
 
 	"github.com/SourLemonJuice/ipapi-agent/response"
-	"github.com/biter777/countries"
 )
 
 /*
@@ -27,13 +26,32 @@ Example: https://ipinfo.io/1.1.1.1/json
 		"readme": "https://ipinfo.io/missingauth",
 		"anycast": true
 	}
+
+The privacy block only exists in some of the responses, looks like:
+
+	"privacy": {
+		"vpn": false,
+		"proxy": false,
+		"tor": false,
+		"relay": false,
+		"hosting": true,
+		"service": ""
+	}
 */
 type ipinfoFree struct {
+	Hostname string `json:"hostname"`
 	Region   string `json:"region"`
 	Country  string `json:"country"`
 	Org      string `json:"org"`
 	Timezone string `json:"timezone"`
 	Anycast  bool   `json:"anycast"`
+	Privacy  *struct {
+		VPN     bool `json:"vpn"`
+		Proxy   bool `json:"proxy"`
+		Tor     bool `json:"tor"`
+		Relay   bool `json:"relay"`
+		Hosting bool `json:"hosting"`
+	} `json:"privacy"`
 }
 
 func (data *ipinfoFree) Fetch(ctx context.Context, addr string) (resp response.Query, err error) {
@@ -43,16 +61,15 @@ func (data *ipinfoFree) Fetch(ctx context.Context, addr string) (resp response.Q
 	}
 
 	resp.DataSource = "IPinfo Free"
+	resp.Hostname = data.Hostname
+	// country name will be filled by the local dataset
 	resp.CountryCode = data.Country
 	resp.Region = data.Region
 
-	country := countries.ByName(data.Country)
-	resp.Country = country.Info().Name
-
 	resp.Timezone = data.Timezone
-	resp.UTCOffset, err = timezoneToUTCOffset(data.Timezone)
+	err = checkTimezone(data.Timezone)
 	if err != nil {
-		return resp, fmt.Errorf("can not convert UTC offset: %w", err)
+		return resp, err
 	}
 
 	// split the first space, first part is ASN, second is Org and ISP:
@@ -62,9 +79,22 @@ func (data *ipinfoFree) Fetch(ctx context.Context, addr string) (resp response.Q
 		return resp, errors.New("wrong organization format of IPinfo Free")
 	}
 	resp.ASN = before
+	resp.AS.Number, err = ASNumber(before)
+	if err != nil {
+		return resp, fmt.Errorf("can not convert ASN: %w", err)
+	}
+	resp.AS.Name = after
 	resp.Org = after
 	resp.ISP = resp.Org
 	resp.Anycast = data.Anycast
 
+	if data.Privacy != nil {
+		resp.Privacy.VPN = data.Privacy.VPN
+		// treat the relay(like iCloud Private Relay) as a kind of proxy
+		resp.Privacy.Proxy = data.Privacy.Proxy || data.Privacy.Relay
+		resp.Privacy.Tor = data.Privacy.Tor
+		resp.Privacy.Hosting = data.Privacy.Hosting
+	}
+
 	return resp, nil
 }
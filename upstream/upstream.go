@@ -3,8 +3,11 @@ package upstream
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/SourLemonJuice/ipapi-agent/response"
@@ -40,12 +43,27 @@ func fetchJSON(ctx context.Context, url string, data API) error {
 	return nil
 }
 
-func timezoneToUTCOffset(tzStr string) (int, error) {
-	tz, err := time.LoadLocation(tzStr)
+// Only check the timezone is known, the UTC offset will be calculated when responding.
+func checkTimezone(tzStr string) error {
+	_, err := time.LoadLocation(tzStr)
 	if err != nil {
-		return 0, fmt.Errorf("can not load timezone: %w", err)
+		return fmt.Errorf("can not load timezone: %w", err)
 	}
 
-	_, offset_sec := time.Now().In(tz).Zone()
-	return offset_sec / 60, nil
+	return nil
+}
+
+// Convert ASN string like "AS13335" to number, also used by the overrides in config.
+func ASNumber(asn string) (uint32, error) {
+	numStr, found := strings.CutPrefix(asn, "AS")
+	if !found {
+		return 0, errors.New("wrong ASN format")
+	}
+
+	num, err := strconv.ParseUint(numStr, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("wrong ASN format: %w", err)
+	}
+
+	return uint32(num), nil
 }
@@ -0,0 +1,34 @@
+package upstream
+
+import "testing"
+
+func TestASNumber(t *testing.T) {
+	tests := []struct {
+		asn     string
+		want    uint32
+		wantErr bool
+	}{
+		{"AS13335", 13335, false},
+		{"AS0", 0, false},
+		{"AS4294967295", 4294967295, false},
+		{"AS4294967296", 0, true},
+		{"13335", 0, true},
+		{"as13335", 0, true},
+		{"AS", 0, true},
+		{"AS-1", 0, true},
+		{"AS13335 Cloudflare", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.asn, func(t *testing.T) {
+			got, err := ASNumber(tt.asn)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ASNumber(%q) error = %v, wantErr %v", tt.asn, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ASNumber(%q) = %v, want %v", tt.asn, got, tt.want)
+			}
+		})
+	}
+}